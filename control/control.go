@@ -0,0 +1,351 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/pause"
+)
+
+// ErrUnknownTask should be given in a Response to a RunTask Request for a
+// task which is not in the schedule, or a ResumeTask Request for a pattern
+// which is not paused
+var ErrUnknownTask = errors.New("Unknown task")
+
+// ErrStandby is given for any Request made while the main loop is not running,
+// as a standby waiting to acquire the leader lock
+var ErrStandby = errors.New("Not the leader: standby, waiting to acquire the leader lock")
+
+type Action int
+
+const (
+	Pause Action = iota
+	Resume
+	RunTask
+	PauseTask
+	ResumeTask
+)
+
+// A Request is passed from the HTTP API to the main loop, which must send
+// exactly one Response on Reply.
+type Request struct {
+	Action Action
+
+	// (optional) for Pause and PauseTask, how long to pause before
+	// automatically resuming
+	Duration time.Duration
+
+	// for RunTask, the name of the task to run. For PauseTask and ResumeTask,
+	// a glob pattern of tasks
+	Task string
+
+	// (optional) for PauseTask, why the tasks are paused
+	Reason string
+
+	Reply chan *Response
+}
+
+type Response struct {
+	Status *taskrunner.TaskStatus
+	Error  error
+}
+
+// The Status of the main loop, as published by the main loop
+type Status struct {
+	State       string           `json:"state"`
+	PausedUntil *time.Time       `json:"paused_until,omitempty"`
+	LastTick    *time.Time       `json:"last_tick,omitempty"`
+	NextTick    *time.Time       `json:"next_tick,omitempty"`
+	Retries     map[string]int64 `json:"retries"`
+	MaxRetries  int64            `json:"max_retries"`
+	PausedTasks []*pause.Pause   `json:"paused_tasks"`
+}
+
+const (
+	StateRunning = "running"
+	StatePaused  = "paused"
+
+	// waiting to acquire the leader lock, in which state no Requests are
+	// passed to the main loop
+	StateStandby = "standby"
+)
+
+// A Server provides a JSON API for inspecting and controlling the main loop.
+// The main loop is expected to publish its status via Update, and to handle
+// anything received from Requests.
+type Server struct {
+	mu       sync.Mutex
+	status   Status
+	requests chan *Request
+	planner  func() schedule.Schedule
+	location *time.Location
+	mux      *http.ServeMux
+}
+
+// NewServer creates a Server. The planner is called for each request for
+// upcoming runs, and must return a Schedule which is safe to Tick without
+// affecting the main loop (eg: a freshly-wrapped Crontab).
+func NewServer(planner func() schedule.Schedule, location *time.Location) *Server {
+	s := &Server{
+		status:   Status{State: StateRunning, Retries: map[string]int64{}, PausedTasks: []*pause.Pause{}},
+		requests: make(chan *Request),
+		planner:  planner,
+		location: location,
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/schedule", s.handleSchedule)
+	s.mux.HandleFunc("/pause", s.handlePause)
+	s.mux.HandleFunc("/resume", s.handleResume)
+	s.mux.HandleFunc("/run", s.handleRun)
+	s.mux.HandleFunc("/tasks/pause", s.handlePauseTask)
+	s.mux.HandleFunc("/tasks/resume", s.handleResumeTask)
+
+	return s
+}
+
+func (s *Server) Requests() <-chan *Request {
+	return s.requests
+}
+
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+func (s *Server) Update(update func(status *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update(&s.status)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle registers an additional handler, eg: for metrics
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// submit passes a request to the main loop, and waits for the response
+func (s *Server) submit(r *http.Request, request *Request) (*Response, error) {
+	if s.Status().State == StateStandby {
+		return nil, ErrStandby
+	}
+
+	request.Reply = make(chan *Response, 1)
+
+	select {
+	case s.requests <- request:
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+
+	select {
+	case response := <-request.Reply:
+		return response, nil
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+func writeJson(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJson(w, code, map[string]string{"error": err.Error()})
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method must be %s", method))
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.Status())
+}
+
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	from := time.Now().In(s.location)
+	if value := r.URL.Query().Get("from"); value != "" {
+		var err error
+		from, err = time.ParseInLocation("2006-01-02 15:04:05", value, s.location)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Failed to parse from: %s", err))
+			return
+		}
+	}
+
+	until := from.Add(time.Hour * 24)
+	if value := r.URL.Query().Get("until"); value != "" {
+		var err error
+		until, err = time.ParseInLocation("2006-01-02 15:04:05", value, s.location)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Failed to parse until: %s", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = schedule.DumpJson(w, s.planner(), from.Add(-1), until)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	request := &Request{Action: Pause}
+	if value := r.URL.Query().Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid duration '%s'", value))
+			return
+		}
+
+		request.Duration = duration
+	}
+
+	s.respond(w, r, request)
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	s.respond(w, r, &Request{Action: Resume})
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	task := r.URL.Query().Get("task")
+	if task == "" {
+		writeError(w, http.StatusBadRequest, errors.New("task is required"))
+		return
+	}
+
+	s.respond(w, r, &Request{Action: RunTask, Task: task})
+}
+
+func (s *Server) handlePauseTask(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	request := &Request{Action: PauseTask, Task: r.URL.Query().Get("task"), Reason: r.URL.Query().Get("reason")}
+	if request.Task == "" {
+		writeError(w, http.StatusBadRequest, errors.New("task is required"))
+		return
+	}
+
+	if value := r.URL.Query().Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid duration '%s'", value))
+			return
+		}
+
+		request.Duration = duration
+	}
+
+	s.respond(w, r, request)
+}
+
+func (s *Server) handleResumeTask(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	task := r.URL.Query().Get("task")
+	if task == "" {
+		writeError(w, http.StatusBadRequest, errors.New("task is required"))
+		return
+	}
+
+	s.respond(w, r, &Request{Action: ResumeTask, Task: task})
+}
+
+type taskResult struct {
+	Task     string   `json:"task"`
+	Ran      bool     `json:"ran"`
+	Running  bool     `json:"running"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings"`
+}
+
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, request *Request) {
+	response, err := s.submit(r, request)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	if response.Error == ErrUnknownTask {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown task '%s'", request.Task))
+		return
+	}
+
+	if errors.Is(response.Error, path.ErrBadPattern) {
+		writeError(w, http.StatusBadRequest, response.Error)
+		return
+	}
+
+	if response.Error != nil {
+		writeError(w, http.StatusInternalServerError, response.Error)
+		return
+	}
+
+	if response.Status == nil {
+		writeJson(w, http.StatusOK, s.Status())
+		return
+	}
+
+	result := taskResult{
+		Task:     request.Task,
+		Ran:      response.Status.Ran,
+		Running:  response.Status.Running,
+		Warnings: []string{},
+	}
+
+	if response.Status.Error != nil {
+		result.Error = response.Status.Error.Error()
+	}
+
+	for _, warning := range response.Status.Warnings {
+		result.Warnings = append(result.Warnings, warning.Error())
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
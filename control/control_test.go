@@ -0,0 +1,213 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func newTestServer() *Server {
+	return NewServer(func() schedule.Schedule {
+		basic := schedule.NewBasicSchedule()
+		basic.Set("test", schedule.NextTime(time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)))
+		return basic
+	}, time.UTC)
+}
+
+// answer handles a single request, as the main loop would
+func answer(server *Server, handle func(*Request) *Response) chan *Request {
+	handled := make(chan *Request, 1)
+	go func() {
+		request := <-server.Requests()
+		request.Reply <- handle(request)
+		handled <- request
+	}()
+
+	return handled
+}
+
+func TestServer(t *testing.T) {
+	t.Run("GET /status should return the published Status", func(t *testing.T) {
+		server := newTestServer()
+		tick := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		server.Update(func(status *Status) {
+			status.State = StatePaused
+			status.NextTick = &tick
+			status.Retries = map[string]int64{"test": 2}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("GET /status did not succeed: %d", recorder.Code)
+		}
+
+		var status Status
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatalf("GET /status did not return valid JSON: %s", err)
+		}
+
+		if status.State != StatePaused || !status.NextTick.Equal(tick) || status.Retries["test"] != 2 {
+			t.Fatalf("GET /status did not return the published Status: %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("GET /schedule should dump the planned schedule", func(t *testing.T) {
+		server := newTestServer()
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET",
+			"/schedule?from=2006-01-02+15:00:00&until=2006-01-02+16:00:00", nil))
+
+		expected := "[{\"when\":\"2006-01-02 15:04:00\",\"tasks\":[\"test\"]}]"
+		if recorder.Body.String() != expected {
+			t.Fatalf("GET /schedule did not return the expected JSON: %s", recorder.Body.String())
+		}
+
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/schedule?from=yesterday", nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("GET /schedule with an invalid time did not fail: %d", recorder.Code)
+		}
+	})
+
+	t.Run("POST /pause should pass a duration to the main loop", func(t *testing.T) {
+		server := newTestServer()
+		handled := answer(server, func(request *Request) *Response {
+			return &Response{}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/pause?duration=5m", nil))
+
+		request := <-handled
+		if request.Action != Pause || request.Duration != 5*time.Minute {
+			t.Fatalf("POST /pause did not pass the expected request: %+v", request)
+		}
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("POST /pause did not succeed: %d", recorder.Code)
+		}
+	})
+
+	t.Run("A standby should report its state, and refuse requests", func(t *testing.T) {
+		server := newTestServer()
+		server.Update(func(status *Status) { status.State = StateStandby })
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+		if !strings.Contains(recorder.Body.String(), `"state":"standby"`) {
+			t.Fatalf("GET /status did not report standby: %s", recorder.Body.String())
+		}
+
+		for _, path := range []string{"/pause", "/resume", "/run?task=test", "/tasks/pause?task=test", "/tasks/resume?task=test"} {
+			recorder = httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("POST", path, nil))
+			if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), "Not the leader") {
+				t.Fatalf("POST %s was not refused by a standby: %d %s", path, recorder.Code, recorder.Body.String())
+			}
+		}
+	})
+
+	t.Run("Control endpoints should require POST", func(t *testing.T) {
+		server := newTestServer()
+		for _, path := range []string{"/pause", "/resume", "/run?task=test", "/tasks/pause?task=test", "/tasks/resume?task=test"} {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+
+			if recorder.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("GET %s did not fail: %d", path, recorder.Code)
+			}
+		}
+	})
+
+	t.Run("POST /run should return the TaskStatus", func(t *testing.T) {
+		server := newTestServer()
+		handled := answer(server, func(request *Request) *Response {
+			return &Response{Status: &taskrunner.TaskStatus{
+				Ran:      false,
+				Warnings: []error{errors.New("intentional warning")},
+			}}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/run?task=test", nil))
+
+		request := <-handled
+		if request.Action != RunTask || request.Task != "test" {
+			t.Fatalf("POST /run did not pass the expected request: %+v", request)
+		}
+
+		if !strings.Contains(recorder.Body.String(), "intentional warning") {
+			t.Fatalf("POST /run did not include the warning: %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("POST /tasks/pause should pass the pattern, duration and reason", func(t *testing.T) {
+		server := newTestServer()
+		handled := answer(server, func(request *Request) *Response {
+			return &Response{}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST",
+			"/tasks/pause?task=billing-*&duration=1h&reason=migration", nil))
+
+		request := <-handled
+		if request.Action != PauseTask || request.Task != "billing-*" ||
+			request.Duration != time.Hour || request.Reason != "migration" {
+			t.Fatalf("POST /tasks/pause did not pass the expected request: %+v", request)
+		}
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("POST /tasks/pause did not succeed: %d", recorder.Code)
+		}
+
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/tasks/pause", nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("POST /tasks/pause without a task did not fail: %d", recorder.Code)
+		}
+	})
+
+	t.Run("POST /tasks/resume should pass the pattern", func(t *testing.T) {
+		server := newTestServer()
+		handled := answer(server, func(request *Request) *Response {
+			return &Response{Error: ErrUnknownTask}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/tasks/resume?task=billing-*", nil))
+
+		request := <-handled
+		if request.Action != ResumeTask || request.Task != "billing-*" {
+			t.Fatalf("POST /tasks/resume did not pass the expected request: %+v", request)
+		}
+
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("POST /tasks/resume of a pattern which is not paused did not 404: %d", recorder.Code)
+		}
+	})
+
+	t.Run("POST /run for an unknown task should 404", func(t *testing.T) {
+		server := newTestServer()
+		answer(server, func(request *Request) *Response {
+			return &Response{Error: ErrUnknownTask}
+		})
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/run?task=unknown", nil))
+
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("POST /run for an unknown task did not 404: %d", recorder.Code)
+		}
+	})
+}
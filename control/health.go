@@ -0,0 +1,142 @@
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health tracks whether the main loop is making progress. The main loop is
+// expected to call Expect whenever it decides when it will next tick, and
+// ObserveAPI should be given the outcome of each ECS API call.
+type Health struct {
+	mu sync.Mutex
+
+	// how long past an expected tick before the main loop is considered wedged
+	grace time.Duration
+
+	// how long ECS API calls may fail continuously before being considered
+	// unhealthy (0 to never consider API failures unhealthy)
+	apiFailureLimit time.Duration
+
+	ready        bool
+	standby      bool
+	expected     time.Time
+	failingSince time.Time
+	now          func() time.Time
+}
+
+func NewHealth(grace time.Duration, apiFailureLimit time.Duration) *Health {
+	return &Health{grace: grace, apiFailureLimit: apiFailureLimit, now: time.Now}
+}
+
+// Expect records when the main loop next expects to tick. A zero time means
+// no tick is expected (eg: while paused indefinitely).
+func (h *Health) Expect(next time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.ready = true
+	h.standby = false
+	h.expected = next
+}
+
+// Standby records that the main loop is waiting to acquire the leader lock,
+// until the next call to Expect. A standby is alive, but not ready.
+func (h *Health) Standby() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.standby = true
+}
+
+// ObserveAPI records the outcome of a single ECS API call, and is suitable for
+// use with ecstaskrunner.NewObservedService
+func (h *Health) ObserveAPI(operation string, duration time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		h.failingSince = time.Time{}
+	} else if h.failingSince.IsZero() {
+		h.failingSince = h.now()
+	}
+}
+
+// Check returns an error if the main loop appears to be wedged, or if ECS API
+// calls have been failing for too long
+func (h *Health) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if !h.expected.IsZero() && now.Sub(h.expected) > h.grace {
+		return fmt.Errorf("Tick expected at %v has not happened after %v",
+			h.expected, now.Sub(h.expected))
+	}
+
+	if h.apiFailureLimit > 0 && !h.failingSince.IsZero() &&
+		now.Sub(h.failingSince) > h.apiFailureLimit {
+		return fmt.Errorf("ECS API calls have been failing since %v", h.failingSince)
+	}
+
+	return nil
+}
+
+// Ready returns an error until the main loop has started, or while it is a
+// standby
+func (h *Health) Ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.standby {
+		return ErrStandby
+	}
+
+	if !h.ready {
+		return fmt.Errorf("Not yet started")
+	}
+
+	return nil
+}
+
+func (h *Health) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	serveCheck(w, r, h.Check)
+}
+
+func (h *Health) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	serveCheck(w, r, h.Ready)
+}
+
+func serveCheck(w http.ResponseWriter, r *http.Request, check func() error) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if err := check(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// CheckURL requests a health endpoint, returning an error unless it reports
+// success. This allows a container HEALTHCHECK without needing curl.
+func CheckURL(url string, timeout time.Duration) error {
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body := make([]byte, 512)
+		n, _ := response.Body.Read(body)
+		return fmt.Errorf("%s: %s", response.Status, body[:n])
+	}
+
+	return nil
+}
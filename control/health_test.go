@@ -0,0 +1,116 @@
+package control
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	t.Run("Should be unhealthy when an expected tick is overdue", func(t *testing.T) {
+		health := NewHealth(time.Minute, 0)
+		now := start
+		health.now = func() time.Time { return now }
+
+		health.Expect(start.Add(time.Minute))
+		now = start.Add(2 * time.Minute)
+		if err := health.Check(); err != nil {
+			t.Fatalf("Tick within the grace period was considered unhealthy: %s", err)
+		}
+
+		now = start.Add(2*time.Minute + time.Second)
+		if err := health.Check(); err == nil {
+			t.Fatalf("Tick beyond the grace period was considered healthy")
+		}
+
+		health.Expect(time.Time{})
+		if err := health.Check(); err != nil {
+			t.Fatalf("No expected tick was considered unhealthy: %s", err)
+		}
+	})
+
+	t.Run("Should be unhealthy when API calls fail continuously", func(t *testing.T) {
+		health := NewHealth(time.Minute, 10*time.Minute)
+		now := start
+		health.now = func() time.Time { return now }
+
+		health.ObserveAPI("RunTask", time.Second, errors.New("intentional"))
+		now = start.Add(5 * time.Minute)
+		health.ObserveAPI("RunTask", time.Second, errors.New("intentional"))
+		if err := health.Check(); err != nil {
+			t.Fatalf("Failures within the limit were considered unhealthy: %s", err)
+		}
+
+		now = start.Add(11 * time.Minute)
+		if err := health.Check(); err == nil {
+			t.Fatalf("Failures beyond the limit were considered healthy")
+		}
+
+		health.ObserveAPI("RunTask", time.Second, nil)
+		if err := health.Check(); err != nil {
+			t.Fatalf("A successful call did not restore health: %s", err)
+		}
+	})
+
+	t.Run("Should be ready only once a tick is expected", func(t *testing.T) {
+		health := NewHealth(time.Minute, 0)
+
+		recorder := httptest.NewRecorder()
+		health.ServeReadiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Was ready before starting: %d", recorder.Code)
+		}
+
+		health.Expect(time.Time{})
+		recorder = httptest.NewRecorder()
+		health.ServeReadiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Was not ready after starting: %d", recorder.Code)
+		}
+	})
+
+	t.Run("A standby should be alive, but not ready", func(t *testing.T) {
+		health := NewHealth(time.Minute, 0)
+		health.Standby()
+
+		recorder := httptest.NewRecorder()
+		health.ServeLiveness(recorder, httptest.NewRequest("GET", "/healthz", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Standby was not alive: %d", recorder.Code)
+		}
+
+		recorder = httptest.NewRecorder()
+		health.ServeReadiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), "Not the leader") {
+			t.Fatalf("Standby was not reported as such: %d %s", recorder.Code, recorder.Body.String())
+		}
+
+		health.Expect(time.Time{})
+		if err := health.Ready(); err != nil {
+			t.Fatalf("Was not ready after acquiring the lock: %s", err)
+		}
+	})
+
+	t.Run("CheckURL should report unhealthy endpoints", func(t *testing.T) {
+		health := NewHealth(time.Minute, 0)
+		now := start
+		health.now = func() time.Time { return now }
+		backend := httptest.NewServer(http.HandlerFunc(health.ServeLiveness))
+		defer backend.Close()
+
+		if err := CheckURL(backend.URL, time.Second); err != nil {
+			t.Fatalf("Healthy endpoint was reported as unhealthy: %s", err)
+		}
+
+		health.Expect(start)
+		now = start.Add(time.Hour)
+		if err := CheckURL(backend.URL, time.Second); err == nil {
+			t.Fatalf("Unhealthy endpoint was reported as healthy")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package deadman
+
+import (
+	"time"
+
+	"github.com/wpalmer/ecscron/history"
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+// A Lookup provides the Nexter for each task, eg: a Crontab
+type Lookup interface {
+	Tasks() []string
+	Nexter(task string) (schedule.Nexter, bool)
+}
+
+// Overdue describes a task which has not succeeded since Expected (plus the
+// grace period)
+type Overdue struct {
+	Task     string
+	Expected time.Time
+
+	// the last success, or when monitoring began if there has been none
+	LastSuccess time.Time
+}
+
+// A Monitor compares each task's expected runs with its actual successful
+// launches, to notice tasks which have silently stopped running (eg: due to
+// repeated failures, or always being skipped as still-running).
+type Monitor struct {
+	lookup      Lookup
+	grace       time.Duration
+	lastSuccess map[string]time.Time
+	alerted     map[string]time.Time
+}
+
+// NewMonitor creates a Monitor which considers every task to have last
+// succeeded at "started", unless told otherwise via Succeeded
+func NewMonitor(lookup Lookup, grace time.Duration, started time.Time) *Monitor {
+	m := &Monitor{
+		lookup:      lookup,
+		grace:       grace,
+		lastSuccess: make(map[string]time.Time),
+		alerted:     make(map[string]time.Time),
+	}
+
+	for _, task := range lookup.Tasks() {
+		m.lastSuccess[task] = started
+	}
+
+	return m
+}
+
+// Succeeded records a successful run of a task, which was scheduled "at"
+func (m *Monitor) Succeeded(task string, at time.Time) {
+	if last, ok := m.lastSuccess[task]; ok && last.After(at) {
+		return
+	}
+
+	m.lastSuccess[task] = at
+}
+
+// Observe records any successful runs within the results of a tick. Runs
+// deliberately suppressed (eg: by a maintenance window) also count as
+// successful, as nothing is wrong.
+func (m *Monitor) Observe(results map[string]*taskrunner.TaskStatus, at time.Time) {
+	for task, result := range results {
+		if result.Ran {
+			m.Succeeded(task, at)
+			continue
+		}
+
+		for _, warning := range result.Warnings {
+			if suppression.IsDeliberate(warning) {
+				m.Succeeded(task, at)
+				break
+			}
+		}
+	}
+}
+
+// Replay records the successful runs within the run history, eg: after a
+// restart, by the same rule as Observe
+func (m *Monitor) Replay(records []history.Record) {
+	for _, record := range records {
+		if record.Outcome == history.OutcomeLaunched || record.Deliberate {
+			m.Succeeded(record.Task, record.When())
+		}
+	}
+}
+
+// Check returns the tasks which are newly overdue as of "now", in the order
+// given by the Lookup. Each task is reported once, until it next succeeds.
+func (m *Monitor) Check(now time.Time) []Overdue {
+	overdue := []Overdue{}
+	for _, task := range m.lookup.Tasks() {
+		nexter, ok := m.lookup.Nexter(task)
+		if !ok {
+			continue
+		}
+
+		last, ok := m.lastSuccess[task]
+		if !ok {
+			last = now
+			m.lastSuccess[task] = last
+		}
+
+		expected := nexter.Next(last)
+		if expected.IsZero() || !now.After(expected.Add(m.grace)) {
+			continue
+		}
+
+		if alerted, ok := m.alerted[task]; ok && alerted.Equal(expected) {
+			continue
+		}
+
+		m.alerted[task] = expected
+		overdue = append(overdue, Overdue{Task: task, Expected: expected, LastSuccess: last})
+	}
+
+	return overdue
+}
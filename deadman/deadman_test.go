@@ -0,0 +1,103 @@
+package deadman
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/history"
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/schedule/maintenance"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func hourly() schedule.Nexter {
+	return schedule.NextFunc(func(after time.Time) time.Time {
+		return after.Truncate(time.Hour).Add(time.Hour)
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 30, 0, 0, time.UTC)
+
+	t.Run("Tasks should be overdue only after the grace period", func(t *testing.T) {
+		basic := schedule.NewBasicSchedule()
+		basic.Set("test", hourly())
+		monitor := NewMonitor(basic, 10*time.Minute, start)
+
+		if overdue := monitor.Check(start.Add(40 * time.Minute)); len(overdue) != 0 {
+			t.Fatalf("Task was overdue within the grace period: %+v", overdue)
+		}
+
+		overdue := monitor.Check(start.Add(41 * time.Minute))
+		if len(overdue) != 1 || overdue[0].Task != "test" ||
+			!overdue[0].Expected.Equal(time.Date(2006, 1, 2, 16, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Task was not overdue after the grace period: %+v", overdue)
+		}
+
+		if overdue := monitor.Check(start.Add(3 * time.Hour)); len(overdue) != 0 {
+			t.Fatalf("Overdue task was reported more than once: %+v", overdue)
+		}
+	})
+
+	t.Run("Successful runs should reset the expectation", func(t *testing.T) {
+		basic := schedule.NewBasicSchedule()
+		basic.Set("test", hourly())
+		monitor := NewMonitor(basic, 10*time.Minute, start)
+
+		at := time.Date(2006, 1, 2, 16, 0, 0, 0, time.UTC)
+		monitor.Observe(map[string]*taskrunner.TaskStatus{
+			"test": &taskrunner.TaskStatus{Ran: true},
+		}, at)
+
+		if overdue := monitor.Check(at.Add(20 * time.Minute)); len(overdue) != 0 {
+			t.Fatalf("Task which succeeded was overdue: %+v", overdue)
+		}
+
+		overdue := monitor.Check(at.Add(71 * time.Minute))
+		if len(overdue) != 1 || !overdue[0].LastSuccess.Equal(at) {
+			t.Fatalf("Task was not overdue after missing its next run: %+v", overdue)
+		}
+	})
+
+	t.Run("Skipped-as-running should not count as success, but maintenance should", func(t *testing.T) {
+		basic := schedule.NewBasicSchedule()
+		basic.Set("running", hourly())
+		basic.Set("maintenance", hourly())
+		monitor := NewMonitor(basic, 10*time.Minute, start)
+
+		at := time.Date(2006, 1, 2, 16, 0, 0, 0, time.UTC)
+		monitor.Observe(map[string]*taskrunner.TaskStatus{
+			"running": &taskrunner.TaskStatus{Running: true},
+			"maintenance": &taskrunner.TaskStatus{Warnings: []error{
+				&maintenance.SuppressedError{Window: &maintenance.Window{Name: "deploy"}, Until: at},
+			}},
+		}, at)
+
+		overdue := monitor.Check(at.Add(20 * time.Minute))
+		if len(overdue) != 1 || overdue[0].Task != "running" {
+			t.Fatalf("Expected only the still-running task to be overdue: %+v", overdue)
+		}
+	})
+
+	t.Run("Replaying history should follow the same rule as Observe", func(t *testing.T) {
+		basic := schedule.NewBasicSchedule()
+		basic.Set("running", hourly())
+		basic.Set("maintenance", hourly())
+		basic.Set("launched", hourly())
+		monitor := NewMonitor(basic, 10*time.Minute, start)
+
+		at := time.Date(2006, 1, 2, 16, 0, 0, 0, time.UTC)
+		monitor.Replay(history.FromResults(map[string]*taskrunner.TaskStatus{
+			"running": &taskrunner.TaskStatus{Running: true},
+			"maintenance": &taskrunner.TaskStatus{Warnings: []error{
+				&maintenance.SuppressedError{Window: &maintenance.Window{Name: "deploy"}, Until: at},
+			}},
+			"launched": &taskrunner.TaskStatus{Ran: true},
+		}, at, at))
+
+		overdue := monitor.Check(at.Add(20 * time.Minute))
+		if len(overdue) != 1 || overdue[0].Task != "running" {
+			t.Fatalf("Expected only the still-running task to be overdue: %+v", overdue)
+		}
+	})
+}
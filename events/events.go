@@ -0,0 +1,157 @@
+package events
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+type Type string
+
+const (
+	TickStarted    Type = "tick_started"
+	TaskLaunched   Type = "task_launched"
+	TaskSkipped    Type = "task_skipped"
+	TaskFailed     Type = "task_failed"
+	TaskThrottled  Type = "task_throttled"
+	RetryExhausted Type = "retry_exhausted"
+	TaskOverdue    Type = "task_overdue"
+	Paused         Type = "paused"
+	Resumed        Type = "resumed"
+)
+
+var Types = []Type{TickStarted, TaskLaunched, TaskSkipped, TaskFailed, TaskThrottled, RetryExhausted, TaskOverdue, Paused, Resumed}
+
+// An Event is something which happened in the main loop
+type Event struct {
+	Type      Type       `json:"type"`
+	Time      time.Time  `json:"time"`
+	Task      string     `json:"task,omitempty"`
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+	Attempt   int64      `json:"attempt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Message   string     `json:"message"`
+}
+
+// A Subscriber receives every Event published to a Bus. Notify is called from
+// the main loop, so must not block.
+type Subscriber interface {
+	Notify(event Event)
+}
+
+type SubscriberFunc func(event Event)
+
+func (f SubscriberFunc) Notify(event Event) {
+	f(event)
+}
+
+type Bus struct {
+	mu          sync.Mutex
+	subscribers []Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	subscribers := append([]Subscriber{}, b.subscribers...)
+	b.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.Notify(event)
+	}
+}
+
+// Outcome classifies the result of running a task as TaskLaunched,
+// TaskSkipped (eg: still running, or deliberately suppressed), TaskThrottled
+// or TaskFailed
+func Outcome(result *taskrunner.TaskStatus) Type {
+	if result.Ran {
+		return TaskLaunched
+	}
+
+	if result.Running {
+		return TaskSkipped
+	}
+
+	if result.Throttled {
+		return TaskThrottled
+	}
+
+	for _, warning := range result.Warnings {
+		if suppression.IsDeliberate(warning) {
+			return TaskSkipped
+		}
+	}
+
+	return TaskFailed
+}
+
+// FromResults creates the Events describing the results of a tick.
+// "scheduled" is the tick in which the tasks ran, or zero if they were run on
+// demand.
+func FromResults(results map[string]*taskrunner.TaskStatus, scheduled time.Time, now time.Time) []Event {
+	tasks := make([]string, 0, len(results))
+	for task := range results {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	events := []Event{}
+	for _, task := range tasks {
+		result := results[task]
+		event := Event{Time: now, Task: task}
+		if !scheduled.IsZero() {
+			event.Scheduled = &scheduled
+		}
+
+		info, isRetry := result.Info.(*retry.RetryInfo)
+		if isRetry {
+			event.Attempt = info.Attempt
+		}
+
+		messages := []string{}
+		if result.Error != nil {
+			messages = append(messages, result.Error.Error())
+		}
+
+		for _, warning := range result.Warnings {
+			messages = append(messages, warning.Error())
+		}
+		event.Error = strings.Join(messages, "; ")
+
+		event.Type = Outcome(result)
+		switch event.Type {
+		case TaskLaunched:
+			event.Message = "Task launched"
+		case TaskSkipped:
+			event.Message = "Task skipped"
+		default:
+			event.Message = "Task failed"
+		}
+
+		events = append(events, event)
+
+		if isRetry && info.Exhausted {
+			event.Type = RetryExhausted
+			event.Message = "Task failed, and no retries remain"
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
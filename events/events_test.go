@@ -0,0 +1,71 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule/maintenance"
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("Publish should notify every Subscriber", func(t *testing.T) {
+		bus := NewBus()
+		received := []Type{}
+		bus.Subscribe(SubscriberFunc(func(event Event) { received = append(received, event.Type) }))
+		bus.Subscribe(SubscriberFunc(func(event Event) { received = append(received, event.Type) }))
+
+		bus.Publish(Event{Type: Paused})
+		if len(received) != 2 || received[0] != Paused || received[1] != Paused {
+			t.Fatalf("Subscribers were not notified as expected: %v", received)
+		}
+	})
+}
+
+func TestFromResults(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	t.Run("Should classify each result", func(t *testing.T) {
+		events := FromResults(map[string]*taskrunner.TaskStatus{
+			"a-launched": &taskrunner.TaskStatus{Ran: true},
+			"b-running":  &taskrunner.TaskStatus{Running: true},
+			"c-maintenance": &taskrunner.TaskStatus{Warnings: []error{
+				&maintenance.SuppressedError{Window: &maintenance.Window{Name: "deploy"}, Until: at},
+			}},
+			"d-failed":    &taskrunner.TaskStatus{Error: errors.New("intentional")},
+			"e-throttled": &taskrunner.TaskStatus{Throttled: true, Warnings: []error{errors.New("throttled")}},
+		}, at, at)
+
+		expected := []Type{TaskLaunched, TaskSkipped, TaskSkipped, TaskFailed, TaskThrottled}
+		if len(events) != len(expected) {
+			t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+		}
+
+		for i, event := range events {
+			if event.Type != expected[i] {
+				t.Fatalf("Event %d for %s was %s, expected %s", i, event.Task, event.Type, expected[i])
+			}
+
+			if event.Scheduled == nil || !event.Scheduled.Equal(at) {
+				t.Fatalf("Event %d did not include the scheduled time", i)
+			}
+		}
+
+		if events[3].Error != "intentional" {
+			t.Fatalf("Failed event did not include the error: %+v", events[3])
+		}
+	})
+
+	t.Run("Exhausted retries should produce an additional event", func(t *testing.T) {
+		events := FromResults(map[string]*taskrunner.TaskStatus{
+			"test": &taskrunner.TaskStatus{Info: &retry.RetryInfo{Attempt: 3, Exhausted: true}},
+		}, at, at)
+
+		if len(events) != 2 || events[0].Type != TaskFailed || events[1].Type != RetryExhausted ||
+			events[1].Attempt != 3 {
+			t.Fatalf("Exhausted retry did not produce the expected events: %+v", events)
+		}
+	})
+}
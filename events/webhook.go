@@ -0,0 +1,365 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/logging"
+)
+
+const (
+	FormatJson  = "json"
+	FormatSlack = "slack"
+)
+
+// A Webhook is an URL to which matching Events are POSTed
+type Webhook struct {
+	URL    string
+	Format string
+
+	// (optional) only these types of Event are sent
+	Types []Type
+
+	// (optional) only Events for tasks matching one of these globs are sent.
+	// When given, Events which are not about a task are not sent.
+	Tasks []string
+}
+
+func (w *Webhook) Matches(event Event) bool {
+	if len(w.Types) > 0 {
+		found := false
+		for _, t := range w.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(w.Tasks) == 0 {
+		return true
+	}
+
+	for _, pattern := range w.Tasks {
+		if matched, _ := path.Match(pattern, event.Task); matched && event.Task != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Payload returns the body to POST for an Event
+func (w *Webhook) Payload(event Event) ([]byte, error) {
+	if w.Format != FormatSlack {
+		return json.Marshal(event)
+	}
+
+	text := fmt.Sprintf("[ecscron] %s: %s", event.Type, event.Message)
+	if event.Task != "" {
+		text = fmt.Sprintf("%s `%s`", text, event.Task)
+	}
+
+	if event.Attempt > 0 {
+		text = fmt.Sprintf("%s (attempt %d)", text, event.Attempt)
+	}
+
+	if event.Error != "" {
+		text = fmt.Sprintf("%s: %s", text, event.Error)
+	}
+
+	return json.Marshal(map[string]string{"text": text})
+}
+
+// LoadWebhooks reads webhooks, one per line, in the format:
+// <url> [format=json|slack] [events=<type>,...] [tasks=<glob>,...]
+func LoadWebhooks(r io.Reader) ([]*Webhook, error) {
+	webhooks := []*Webhook{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		webhook, err := parseWebhook(line)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %s", lineNumber, err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func parseWebhook(line string) (*Webhook, error) {
+	fields := strings.Fields(line)
+	webhook := &Webhook{URL: fields[0], Format: FormatJson}
+	if !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+		return nil, fmt.Errorf("Invalid webhook URL '%s'", webhook.URL)
+	}
+
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Expected key=value, got '%s'", field)
+		}
+
+		switch parts[0] {
+		case "format":
+			if parts[1] != FormatJson && parts[1] != FormatSlack {
+				return nil, fmt.Errorf("Unknown webhook format '%s'", parts[1])
+			}
+			webhook.Format = parts[1]
+		case "events":
+			for _, name := range strings.Split(parts[1], ",") {
+				known := false
+				for _, t := range Types {
+					if Type(name) == t {
+						known = true
+						break
+					}
+				}
+
+				if !known {
+					return nil, fmt.Errorf("Unknown event type '%s'", name)
+				}
+				webhook.Types = append(webhook.Types, Type(name))
+			}
+		case "tasks":
+			for _, pattern := range strings.Split(parts[1], ",") {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("Invalid task pattern '%s': %s", pattern, err)
+				}
+				webhook.Tasks = append(webhook.Tasks, pattern)
+			}
+		default:
+			return nil, fmt.Errorf("Unknown option '%s'", parts[0])
+		}
+	}
+
+	return webhook, nil
+}
+
+type delivery struct {
+	event   Event
+	attempt int
+	due     time.Time
+}
+
+// A Notifier delivers matching Events to a Webhook in the background, so that
+// slow or failing webhooks never block the main loop. Failed deliveries are
+// retried with an exponential backoff.
+type Notifier struct {
+	webhook     *Webhook
+	client      *http.Client
+	logger      *slog.Logger
+	maxAttempts int
+	backoff     time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+
+	// cancelled by Close once its deadline has passed, abandoning any
+	// delivery in progress, and any still queued
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewNotifier(webhook *Webhook, logger *slog.Logger) *Notifier {
+	return newNotifier(webhook, logger, &http.Client{Timeout: 10 * time.Second}, 5, time.Second)
+}
+
+func newNotifier(webhook *Webhook, logger *slog.Logger, client *http.Client, maxAttempts int, backoff time.Duration) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		webhook:     webhook,
+		client:      client,
+		logger:      logger,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		queue:       make(chan Event, 100),
+		done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	go n.run()
+	return n
+}
+
+// Notify queues a matching Event for delivery. If the queue is full, the
+// Event is dropped.
+func (n *Notifier) Notify(event Event) {
+	if !n.webhook.Matches(event) {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		logging.Event(n.logger, logging.LevelWarn, "webhook_dropped", "Webhook queue is full, dropping event",
+			"url", n.webhook.URL, "type", string(event.Type), logging.KeyTask, event.Task)
+	}
+}
+
+// Close stops accepting Events, and waits until the deadline for those
+// already queued to be attempted. Any deliveries awaiting a retry, or still
+// queued at the deadline, are abandoned (and logged).
+func (n *Notifier) Close(deadline time.Time) {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	defer n.cancel()
+
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	select {
+	case <-n.done:
+	case <-timeout.C:
+		n.cancel()
+		<-n.done
+	}
+}
+
+// abandon logs a delivery which will not be attempted (again)
+func (n *Notifier) abandon(event Event) {
+	logging.Event(n.logger, logging.LevelWarn, "webhook_failed", "Abandoning webhook delivery on shutdown",
+		"url", n.webhook.URL, "type", string(event.Type), logging.KeyTask, event.Task)
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+
+	retries := []*delivery{}
+	for {
+		var retry <-chan time.Time
+		if len(retries) > 0 {
+			earliest := retries[0].due
+			for _, d := range retries {
+				if d.due.Before(earliest) {
+					earliest = d.due
+				}
+			}
+			retry = time.After(time.Until(earliest))
+		}
+
+		select {
+		case event, ok := <-n.queue:
+			if !ok {
+				for _, d := range retries {
+					n.abandon(d.event)
+				}
+				return
+			}
+
+			if n.ctx.Err() != nil {
+				n.abandon(event)
+				continue
+			}
+
+			retries = n.attempt(&delivery{event: event}, retries)
+		case <-retry:
+			now := time.Now()
+			waiting := []*delivery{}
+			for _, d := range retries {
+				if d.due.After(now) {
+					waiting = append(waiting, d)
+					continue
+				}
+				waiting = n.attempt(d, waiting)
+			}
+			retries = waiting
+		}
+	}
+}
+
+// attempt delivers, returning retries with the delivery appended if it should
+// be attempted again
+func (n *Notifier) attempt(d *delivery, retries []*delivery) []*delivery {
+	d.attempt += 1
+	err := n.deliver(d.event)
+	if err == nil {
+		return retries
+	}
+
+	if n.ctx.Err() != nil {
+		n.abandon(d.event)
+		return retries
+	}
+
+	if d.attempt >= n.maxAttempts {
+		logging.Event(n.logger, logging.LevelError, "webhook_failed", "Giving up on webhook delivery",
+			"url", n.webhook.URL, "type", string(d.event.Type), logging.KeyTask, d.event.Task,
+			logging.KeyAttempt, d.attempt, logging.KeyError, err)
+		return retries
+	}
+
+	logging.Event(n.logger, logging.LevelDetail, "webhook_retry", "Webhook delivery failed, will retry",
+		"url", n.webhook.URL, "type", string(d.event.Type), logging.KeyTask, d.event.Task,
+		logging.KeyAttempt, d.attempt, logging.KeyError, err)
+	d.due = time.Now().Add(n.backoff << uint(d.attempt-1))
+	return append(retries, d)
+}
+
+func (n *Notifier) deliver(event Event) error {
+	payload, err := n.webhook.Payload(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
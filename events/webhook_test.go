@@ -0,0 +1,181 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	t.Run("Matches should filter by type and task", func(t *testing.T) {
+		webhook := &Webhook{Types: []Type{TaskFailed}, Tasks: []string{"billing-*"}}
+
+		for _, test := range []struct {
+			event    Event
+			expected bool
+		}{
+			{Event{Type: TaskFailed, Task: "billing-report"}, true},
+			{Event{Type: TaskFailed, Task: "other"}, false},
+			{Event{Type: TaskLaunched, Task: "billing-report"}, false},
+			{Event{Type: TaskFailed}, false},
+		} {
+			if webhook.Matches(test.event) != test.expected {
+				t.Fatalf("Matches(%+v) was not %v", test.event, test.expected)
+			}
+		}
+
+		if !(&Webhook{}).Matches(Event{Type: Paused}) {
+			t.Fatalf("An unfiltered webhook did not match everything")
+		}
+	})
+
+	t.Run("Slack payloads should contain a text summary", func(t *testing.T) {
+		webhook := &Webhook{Format: FormatSlack}
+		payload, _ := webhook.Payload(Event{Type: RetryExhausted, Task: "test", Attempt: 3,
+			Message: "Task failed", Error: "intentional"})
+
+		var decoded map[string]string
+		if err := json.Unmarshal(payload, &decoded); err != nil {
+			t.Fatalf("Payload was not valid JSON: %s", payload)
+		}
+
+		if decoded["text"] != "[ecscron] retry_exhausted: Task failed `test` (attempt 3): intentional" {
+			t.Fatalf("Payload did not contain the expected text: %s", payload)
+		}
+	})
+
+	t.Run("LoadWebhooks should parse options", func(t *testing.T) {
+		webhooks, err := LoadWebhooks(strings.NewReader(
+			"# comment\n" +
+				"https://example.com/hook\n" +
+				"https://hooks.slack.com/x format=slack events=task_failed,retry_exhausted tasks=billing-*\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error loading webhooks: %s", err)
+		}
+
+		if len(webhooks) != 2 || webhooks[0].Format != FormatJson || webhooks[1].Format != FormatSlack ||
+			len(webhooks[1].Types) != 2 || webhooks[1].Tasks[0] != "billing-*" {
+			t.Fatalf("Webhooks were not parsed as expected: %+v", webhooks)
+		}
+
+		for _, line := range []string{
+			"example.com/hook",
+			"https://example.com/hook format=xml",
+			"https://example.com/hook events=unknown",
+			"https://example.com/hook colour=blue",
+		} {
+			if _, err := LoadWebhooks(strings.NewReader(line)); err == nil {
+				t.Fatalf("Invalid line '%s' did not fail", line)
+			}
+		}
+	})
+}
+
+func TestNotifier(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Failed deliveries should be retried", func(t *testing.T) {
+		var mu sync.Mutex
+		attempts := 0
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			attempts += 1
+			if attempts < 3 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer backend.Close()
+
+		notifier := newNotifier(&Webhook{URL: backend.URL}, logger, backend.Client(), 5, time.Millisecond)
+		notifier.Notify(Event{Type: TaskFailed, Task: "test"})
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			done := attempts >= 3
+			mu.Unlock()
+
+			if done {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("Delivery was not retried until successful")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		notifier.Close(time.Now().Add(5 * time.Second))
+		if attempts != 3 {
+			t.Fatalf("Expected exactly 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("Close should abandon deliveries still pending at the deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		attempts := 0
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			attempts += 1
+			mu.Unlock()
+
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer backend.Close()
+		defer close(release)
+
+		notifier := newNotifier(&Webhook{URL: backend.URL}, logger, backend.Client(), 5, time.Millisecond)
+		for i := 0; i < 10; i++ {
+			notifier.Notify(Event{Type: TaskFailed, Task: "test"})
+		}
+
+		started := time.Now()
+		notifier.Close(started.Add(50 * time.Millisecond))
+		if elapsed := time.Since(started); elapsed > 2*time.Second {
+			t.Fatalf("Close did not return at its deadline: took %s", elapsed)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts != 1 {
+			t.Fatalf("Queued deliveries were attempted after the deadline: %d attempts", attempts)
+		}
+	})
+
+	t.Run("Notify should not block on a slow webhook", func(t *testing.T) {
+		release := make(chan struct{})
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer backend.Close()
+		defer close(release)
+
+		notifier := newNotifier(&Webhook{URL: backend.URL}, logger, backend.Client(), 1, time.Millisecond)
+
+		finished := make(chan struct{})
+		go func() {
+			for i := 0; i < 200; i++ {
+				notifier.Notify(Event{Type: TickStarted})
+			}
+			close(finished)
+		}()
+
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Notify blocked on a slow webhook")
+		}
+	})
+}
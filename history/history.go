@@ -0,0 +1,314 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/wpalmer/ecscron/events"
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+const (
+	OutcomeLaunched = "launched"
+	OutcomeSkipped  = "skipped"
+	OutcomeFailed   = "failed"
+
+	// not run because the ECS API throttled the request
+	OutcomeThrottled = "throttled"
+)
+
+// A Record is the result of a single task run
+type Record struct {
+	Task string `json:"task"`
+
+	// the tick in which the task ran, or nil if it was run on demand
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+
+	// when the tick (or on-demand run) actually started
+	Launched time.Time `json:"launched"`
+
+	Outcome  string   `json:"outcome"`
+	Attempt  int64    `json:"attempt,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	TaskArns []string `json:"task_arns,omitempty"`
+
+	// the task was skipped deliberately (eg: paused, or in a maintenance
+	// window), rather than as still-running
+	Deliberate bool `json:"deliberate,omitempty"`
+}
+
+// When returns the time by which the Record is queried: the scheduled time,
+// or the launch time if run on demand
+func (r *Record) When() time.Time {
+	if r.Scheduled != nil {
+		return *r.Scheduled
+	}
+
+	return r.Launched
+}
+
+// FromResults creates a Record for each result, sorted by task
+func FromResults(results map[string]*taskrunner.TaskStatus, scheduled time.Time, launched time.Time) []Record {
+	tasks := make([]string, 0, len(results))
+	for task := range results {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	records := []Record{}
+	for _, task := range tasks {
+		result := results[task]
+		record := Record{Task: task, Launched: launched}
+		if !scheduled.IsZero() {
+			record.Scheduled = &scheduled
+		}
+
+		switch events.Outcome(result) {
+		case events.TaskLaunched:
+			record.Outcome = OutcomeLaunched
+		case events.TaskSkipped:
+			record.Outcome = OutcomeSkipped
+		case events.TaskThrottled:
+			record.Outcome = OutcomeThrottled
+		default:
+			record.Outcome = OutcomeFailed
+		}
+
+		if info, ok := result.Info.(*retry.RetryInfo); ok {
+			record.Attempt = info.Attempt
+		}
+
+		if result.Error != nil {
+			record.Error = result.Error.Error()
+		}
+
+		for _, warning := range result.Warnings {
+			record.Warnings = append(record.Warnings, warning.Error())
+			if !result.Ran && suppression.IsDeliberate(warning) {
+				record.Deliberate = true
+			}
+		}
+
+		if output, ok := result.Output.(*ecs.RunTaskOutput); ok && output != nil {
+			for _, ecsTask := range output.Tasks {
+				record.TaskArns = append(record.TaskArns, aws.StringValue(ecsTask.TaskArn))
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// A Store appends Records, as JSON lines, to a file. When the file would grow
+// beyond maxSize, it is rotated to <path>.1 (and <path>.1 to <path>.2, etc),
+// keeping at most maxFiles files in total.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+}
+
+func NewStore(path string, maxSize int64, maxFiles int) *Store {
+	return &Store{path: path, maxSize: maxSize, maxFiles: maxFiles}
+}
+
+func (s *Store) Append(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.path); err == nil && s.maxSize > 0 &&
+		info.Size() > 0 && info.Size()+int64(buf.Len()) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	// a partial line, eg: from a crash mid-write, is terminated so that it
+	// does not corrupt the first of these records
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf = bytes.NewBuffer(append([]byte("\n"), buf.Bytes()...))
+		}
+	}
+
+	_, err = buf.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (s *Store) rotatedPath(n int) string {
+	if n == 0 {
+		return s.path
+	}
+
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+func (s *Store) rotate() error {
+	if s.maxFiles <= 1 {
+		return os.Remove(s.path)
+	}
+
+	for n := s.maxFiles - 1; n > 0; n-- {
+		err := os.Rename(s.rotatedPath(n-1), s.rotatedPath(n))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A Filter selects Records by task and time. Any zero-valued field matches
+// everything.
+type Filter struct {
+	// a glob, as with path.Match
+	Task string
+
+	// inclusive
+	From time.Time
+
+	// exclusive
+	Until time.Time
+}
+
+func (f *Filter) Matches(record *Record) bool {
+	if f.Task != "" {
+		if matched, _ := path.Match(f.Task, record.Task); !matched {
+			return false
+		}
+	}
+
+	when := record.When()
+	if !f.From.IsZero() && when.Before(f.From) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !when.Before(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Query reads the Records matching the Filter from the store at path,
+// including rotated files, oldest first. Any line which cannot be read (eg: a
+// partial line written before a crash) is skipped, and passed to warn, if
+// not nil.
+func Query(storePath string, filter Filter, warn func(err error)) ([]Record, error) {
+	if _, err := path.Match(filter.Task, ""); err != nil {
+		return nil, fmt.Errorf("Invalid task pattern '%s': %s", filter.Task, err)
+	}
+
+	rotated, err := filepath.Glob(storePath + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	numbered := map[int]string{}
+	numbers := []int{}
+	for _, candidate := range rotated {
+		n, err := strconv.Atoi(strings.TrimPrefix(candidate, storePath+"."))
+		if err != nil || n <= 0 {
+			continue
+		}
+
+		numbered[n] = candidate
+		numbers = append(numbers, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+
+	paths := []string{}
+	for _, n := range numbers {
+		paths = append(paths, numbered[n])
+	}
+	paths = append(paths, storePath)
+
+	records := []Record{}
+	for _, p := range paths {
+		found, err := readRecords(p, &filter, warn)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, found...)
+	}
+
+	return records, nil
+}
+
+func readRecords(p string, filter *Filter, warn func(err error)) ([]Record, error) {
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			if warn != nil {
+				warn(fmt.Errorf("Skipping unreadable record at %s line %d: %s", p, lineNumber, err))
+			}
+			continue
+		}
+
+		if filter.Matches(&record) {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
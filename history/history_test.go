@@ -0,0 +1,151 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func TestFromResults(t *testing.T) {
+	t.Run("Should record the outcome, attempt and task ARNs", func(t *testing.T) {
+		at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		records := FromResults(map[string]*taskrunner.TaskStatus{
+			"a": &taskrunner.TaskStatus{Ran: true, Output: &ecs.RunTaskOutput{
+				Tasks: []*ecs.Task{&ecs.Task{TaskArn: aws.String("arn:test")}},
+			}},
+			"b": &taskrunner.TaskStatus{
+				Warnings: []error{errors.New("intentional")},
+				Info:     &retry.RetryInfo{Attempt: 2},
+			},
+		}, at, at.Add(time.Second))
+
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d", len(records))
+		}
+
+		if records[0].Outcome != OutcomeLaunched || len(records[0].TaskArns) != 1 ||
+			records[0].TaskArns[0] != "arn:test" || !records[0].Scheduled.Equal(at) ||
+			!records[0].Launched.Equal(at.Add(time.Second)) {
+			t.Fatalf("Launched task was not recorded as expected: %+v", records[0])
+		}
+
+		if records[0].Deliberate || records[1].Deliberate {
+			t.Fatalf("Task was recorded as deliberately skipped: %+v", records)
+		}
+
+		if records[1].Outcome != OutcomeFailed || records[1].Attempt != 2 ||
+			len(records[1].Warnings) != 1 || records[1].Warnings[0] != "intentional" {
+			t.Fatalf("Failed task was not recorded as expected: %+v", records[1])
+		}
+	})
+}
+
+func TestStore(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	record := func(task string, offset time.Duration) Record {
+		scheduled := at.Add(offset)
+		return Record{Task: task, Scheduled: &scheduled, Launched: scheduled, Outcome: OutcomeLaunched}
+	}
+
+	t.Run("Query should filter by task and time", func(t *testing.T) {
+		storePath := filepath.Join(t.TempDir(), "history.jsonl")
+		store := NewStore(storePath, 0, 1)
+		if err := store.Append([]Record{
+			record("billing", 0),
+			record("other", 0),
+			record("billing", time.Hour),
+			record("billing", 2*time.Hour),
+		}); err != nil {
+			t.Fatalf("Unexpected error appending: %s", err)
+		}
+
+		records, err := Query(storePath, Filter{Task: "bill*", From: at, Until: at.Add(2 * time.Hour)}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error querying: %s", err)
+		}
+
+		if len(records) != 2 || records[0].Task != "billing" || !records[1].When().Equal(at.Add(time.Hour)) {
+			t.Fatalf("Query did not return the expected records: %+v", records)
+		}
+	})
+
+	t.Run("Append should rotate files, and Query should read them oldest first", func(t *testing.T) {
+		storePath := filepath.Join(t.TempDir(), "history.jsonl")
+		store := NewStore(storePath, 10, 3)
+		for i := 0; i < 4; i++ {
+			if err := store.Append([]Record{record("test", time.Duration(i)*time.Hour)}); err != nil {
+				t.Fatalf("Unexpected error appending: %s", err)
+			}
+		}
+
+		if _, err := os.Stat(storePath + ".2"); err != nil {
+			t.Fatalf("Store was not rotated: %s", err)
+		}
+
+		if _, err := os.Stat(storePath + ".3"); !os.IsNotExist(err) {
+			t.Fatalf("Store kept more than the maximum number of files")
+		}
+
+		records, err := Query(storePath, Filter{}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error querying: %s", err)
+		}
+
+		if len(records) != 3 {
+			t.Fatalf("Expected the 3 most recent records, got %d", len(records))
+		}
+
+		for i, r := range records {
+			if !r.When().Equal(at.Add(time.Duration(i+1) * time.Hour)) {
+				t.Fatalf("Records were not returned oldest first: %+v", records)
+			}
+		}
+	})
+
+	t.Run("Query should skip a partial line, and Append should not extend it", func(t *testing.T) {
+		storePath := filepath.Join(t.TempDir(), "history.jsonl")
+		store := NewStore(storePath, 0, 1)
+		if err := store.Append([]Record{record("before", 0)}); err != nil {
+			t.Fatalf("Unexpected error appending: %s", err)
+		}
+
+		file, err := os.OpenFile(storePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("Unexpected error opening store: %s", err)
+		}
+		_, _ = file.WriteString(`{"task":"partial","laun`)
+		file.Close()
+
+		if err := store.Append([]Record{record("after", time.Hour)}); err != nil {
+			t.Fatalf("Unexpected error appending: %s", err)
+		}
+
+		warnings := []error{}
+		records, err := Query(storePath, Filter{}, func(err error) { warnings = append(warnings, err) })
+		if err != nil {
+			t.Fatalf("Unexpected error querying: %s", err)
+		}
+
+		if len(records) != 2 || records[0].Task != "before" || records[1].Task != "after" {
+			t.Fatalf("Readable records were not returned: %+v", records)
+		}
+
+		if len(warnings) != 1 {
+			t.Fatalf("Expected a warning for the partial line, got %v", warnings)
+		}
+	})
+
+	t.Run("Query of a missing store should return nothing", func(t *testing.T) {
+		records, err := Query(filepath.Join(t.TempDir(), "missing.jsonl"), Filter{}, nil)
+		if err != nil || len(records) != 0 {
+			t.Fatalf("Query of a missing store did not return nothing: %v %v", records, err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// MinimalDynamoDBAPI is the subset of the DynamoDB API used by a DynamoDBLock
+type MinimalDynamoDBAPI interface {
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+}
+
+// A DynamoDBLock is a lease, held in a DynamoDB table item using conditional
+// writes. The table must have a string partition key named "lock_id". The
+// lease expires if not renewed, so replicas' clocks should be roughly in
+// sync.
+type DynamoDBLock struct {
+	service MinimalDynamoDBAPI
+	table   string
+	id      string
+	owner   string
+	lease   time.Duration
+	now     func() time.Time
+}
+
+// NewDynamoDBLock creates a lock named id in the given table, held on behalf
+// of owner (which must be unique to this replica), for lease at a time
+func NewDynamoDBLock(service MinimalDynamoDBAPI, table string, id string, owner string, lease time.Duration) *DynamoDBLock {
+	return &DynamoDBLock{service: service, table: table, id: id, owner: owner, lease: lease, now: time.Now}
+}
+
+func (l *DynamoDBLock) TryAcquire() (bool, error) {
+	now := l.now()
+	_, err := l.service.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"lock_id": {S: aws.String(l.id)},
+			"owner":   {S: aws.String(l.owner)},
+			"expires": {N: aws.String(strconv.FormatInt(now.Add(l.lease).UnixNano()/int64(time.Millisecond), 10))},
+		},
+		// free, expired, or already ours
+		ConditionExpression: aws.String("attribute_not_exists(lock_id) OR expires < :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10))},
+			":owner": {S: aws.String(l.owner)},
+		},
+	})
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (l *DynamoDBLock) Release() error {
+	_, err := l.service.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"lock_id": {S: aws.String(l.id)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(l.owner)},
+		},
+	})
+
+	// already taken over by another replica, which is fine
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package leader
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// fakeDynamoDB is a stand-in for DynamoDB, which understands only the
+// conditions used by DynamoDBLock
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func conditionFailed() error {
+	return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+}
+
+func (d *fakeDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := aws.StringValue(input.Item["lock_id"].S)
+	if existing, ok := d.items[id]; ok {
+		expires, _ := strconv.ParseInt(aws.StringValue(existing["expires"].N), 10, 64)
+		now, _ := strconv.ParseInt(aws.StringValue(input.ExpressionAttributeValues[":now"].N), 10, 64)
+		owner := aws.StringValue(input.ExpressionAttributeValues[":owner"].S)
+
+		if !(expires < now || aws.StringValue(existing["owner"].S) == owner) {
+			return nil, conditionFailed()
+		}
+	}
+
+	d.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *fakeDynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := aws.StringValue(input.Key["lock_id"].S)
+	existing, ok := d.items[id]
+	if !ok || aws.StringValue(existing["owner"].S) != aws.StringValue(input.ExpressionAttributeValues[":owner"].S) {
+		return nil, conditionFailed()
+	}
+
+	delete(d.items, id)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDynamoDBLock(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	t.Run("Only one owner should hold the lease until it expires", func(t *testing.T) {
+		service := newFakeDynamoDB()
+		now := start
+		clock := func() time.Time { return now }
+
+		first := NewDynamoDBLock(service, "locks", "ecscron", "first", 30*time.Second)
+		first.now = clock
+		second := NewDynamoDBLock(service, "locks", "ecscron", "second", 30*time.Second)
+		second.now = clock
+
+		if ok, err := first.TryAcquire(); !ok || err != nil {
+			t.Fatalf("First lease was not acquired: %v", err)
+		}
+
+		now = start.Add(20 * time.Second)
+		if ok, err := second.TryAcquire(); ok || err != nil {
+			t.Fatalf("Second lease was acquired while the first was held: %v", err)
+		}
+
+		if ok, err := first.TryAcquire(); !ok || err != nil {
+			t.Fatalf("First lease was not renewed: %v", err)
+		}
+
+		now = start.Add(45 * time.Second)
+		if ok, _ := second.TryAcquire(); ok {
+			t.Fatalf("Second lease was acquired before the renewed first lease expired")
+		}
+
+		now = start.Add(51 * time.Second)
+		if ok, err := second.TryAcquire(); !ok || err != nil {
+			t.Fatalf("Second lease was not acquired once the first expired: %v", err)
+		}
+
+		if ok, _ := first.TryAcquire(); ok {
+			t.Fatalf("First lease was renewed after being taken over")
+		}
+	})
+
+	t.Run("Release should allow another owner to acquire immediately", func(t *testing.T) {
+		service := newFakeDynamoDB()
+		first := NewDynamoDBLock(service, "locks", "ecscron", "first", time.Hour)
+		second := NewDynamoDBLock(service, "locks", "ecscron", "second", time.Hour)
+
+		_, _ = first.TryAcquire()
+		if err := second.Release(); err != nil {
+			t.Fatalf("Releasing a lease held by another owner failed: %s", err)
+		}
+
+		if ok, _ := second.TryAcquire(); ok {
+			t.Fatalf("Release by another owner released the lease")
+		}
+
+		if err := first.Release(); err != nil {
+			t.Fatalf("Unexpected error releasing: %s", err)
+		}
+
+		if ok, err := second.TryAcquire(); !ok || err != nil {
+			t.Fatalf("Lease was not acquired after being released: %v", err)
+		}
+	})
+}
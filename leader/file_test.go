@@ -0,0 +1,34 @@
+package leader
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLock(t *testing.T) {
+	t.Run("Only one FileLock should be held at a time", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ecscron.lock")
+		first := NewFileLock(path)
+		second := NewFileLock(path)
+
+		if ok, err := first.TryAcquire(); !ok || err != nil {
+			t.Fatalf("First lock was not acquired: %v", err)
+		}
+
+		if ok, err := first.TryAcquire(); !ok || err != nil {
+			t.Fatalf("First lock was not renewed: %v", err)
+		}
+
+		if ok, err := second.TryAcquire(); ok || err != nil {
+			t.Fatalf("Second lock was acquired while the first was held: %v", err)
+		}
+
+		if err := first.Release(); err != nil {
+			t.Fatalf("Unexpected error releasing: %s", err)
+		}
+
+		if ok, err := second.TryAcquire(); !ok || err != nil {
+			t.Fatalf("Second lock was not acquired once the first was released: %v", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"time"
+)
+
+// A Lock is held by at most one ecscron replica at a time. Only the holder
+// runs the main loop.
+type Lock interface {
+	// TryAcquire attempts to acquire the Lock without waiting, or to renew it
+	// if already held, returning true if the Lock is now held
+	TryAcquire() (bool, error)
+
+	// Release gives up the Lock, if held, so that another replica may take
+	// over without waiting for it to expire
+	Release() error
+}
+
+// An Elector acquires and maintains a Lock
+type Elector struct {
+	lock Lock
+
+	// how often to attempt to acquire or renew the Lock
+	interval time.Duration
+
+	// how long the Lock remains held without renewal (for Locks which
+	// expire), after which another replica may acquire it
+	lease time.Duration
+
+	onError func(error)
+}
+
+func NewElector(lock Lock, interval time.Duration, lease time.Duration, onError func(error)) *Elector {
+	return &Elector{lock: lock, interval: interval, lease: lease, onError: onError}
+}
+
+// Campaign blocks until the Lock is acquired, returning true, or until stop
+// is closed, returning false
+func (e *Elector) Campaign(stop <-chan struct{}) bool {
+	for {
+		ok, err := e.lock.TryAcquire()
+		if err != nil {
+			e.onError(err)
+		}
+
+		if ok {
+			return true
+		}
+
+		select {
+		case <-time.After(e.interval):
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// Maintain renews the held Lock in the background, until stop is closed. The
+// returned channel is closed if the Lock is lost, or has not been renewed for
+// so long that another replica may be about to acquire it.
+func (e *Elector) Maintain(stop <-chan struct{}) <-chan struct{} {
+	lost := make(chan struct{})
+
+	go func() {
+		renewed := time.Now()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+
+			ok, err := e.lock.TryAcquire()
+			if err != nil {
+				e.onError(err)
+			}
+
+			if ok {
+				renewed = time.Now()
+				continue
+			}
+
+			if err == nil || time.Since(renewed) >= e.lease-e.interval {
+				close(lost)
+				return
+			}
+		}
+	}()
+
+	return lost
+}
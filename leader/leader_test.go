@@ -0,0 +1,87 @@
+package leader
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLock struct {
+	mu      sync.Mutex
+	results []bool
+	err     error
+	calls   int
+}
+
+func (l *fakeLock) TryAcquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls += 1
+	if len(l.results) == 0 {
+		return false, l.err
+	}
+
+	result := l.results[0]
+	if len(l.results) > 1 {
+		l.results = l.results[1:]
+	}
+
+	return result, l.err
+}
+
+func (l *fakeLock) Release() error {
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	ignore := func(error) {}
+
+	t.Run("Campaign should retry until the lock is acquired", func(t *testing.T) {
+		lock := &fakeLock{results: []bool{false, false, true}}
+		elector := NewElector(lock, time.Millisecond, time.Second, ignore)
+
+		if !elector.Campaign(make(chan struct{})) || lock.calls != 3 {
+			t.Fatalf("Campaign did not acquire the lock on the third attempt: %d", lock.calls)
+		}
+	})
+
+	t.Run("Campaign should give up when stopped", func(t *testing.T) {
+		lock := &fakeLock{results: []bool{false}}
+		elector := NewElector(lock, time.Millisecond, time.Second, ignore)
+
+		stop := make(chan struct{})
+		close(stop)
+		if elector.Campaign(stop) {
+			t.Fatalf("Campaign acquired a lock which was never available")
+		}
+	})
+
+	t.Run("Maintain should report a lost lock", func(t *testing.T) {
+		lock := &fakeLock{results: []bool{true, true, false}}
+		elector := NewElector(lock, time.Millisecond, time.Second, ignore)
+
+		select {
+		case <-elector.Maintain(make(chan struct{})):
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Maintain did not report the lost lock")
+		}
+	})
+
+	t.Run("Maintain should tolerate errors until the lease may have expired", func(t *testing.T) {
+		lock := &fakeLock{results: []bool{false}, err: errors.New("intentional")}
+		elector := NewElector(lock, time.Millisecond, 50*time.Millisecond, ignore)
+
+		started := time.Now()
+		select {
+		case <-elector.Maintain(make(chan struct{})):
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Maintain did not report the lock as lost")
+		}
+
+		if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+			t.Fatalf("Maintain gave up after only %v of errors", elapsed)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Levels, from least to most important. The -debug verbosity levels map onto
+// these via LevelForVerbosity.
+const (
+	LevelStatus = slog.Level(-8)
+	LevelDetail = slog.LevelDebug
+	LevelInfo   = slog.LevelInfo
+
+	// LevelNotice is for events which are always logged, but are not
+	// problems (eg: pausing or resuming)
+	LevelNotice = slog.Level(2)
+	LevelWarn   = slog.LevelWarn
+	LevelError  = slog.LevelError
+)
+
+// Stable field names, which log pipelines may rely upon
+const (
+	KeyEvent                = "event"
+	KeyTask                 = "task"
+	KeyScheduled            = "scheduled"
+	KeyAttempt              = "attempt"
+	KeyMaxAttempts          = "max_attempts"
+	KeyCluster              = "cluster"
+	KeyTaskArn              = "task_arn"
+	KeyTaskDefinitionArn    = "task_definition_arn"
+	KeyContainerInstanceArn = "container_instance_arn"
+	KeyErrorClass           = "error_class"
+	KeyError                = "error"
+	KeyLateness             = "lateness_seconds"
+	KeyWindow               = "window"
+	KeyMissed               = "missed"
+)
+
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+var levelNames = map[slog.Level]string{
+	LevelStatus: "STATUS",
+	LevelDetail: "DETAIL",
+	LevelInfo:   "INFO",
+	LevelNotice: "NOTICE",
+	LevelWarn:   "WARN",
+	LevelError:  "ERROR",
+}
+
+// LevelForVerbosity returns the minimum Level logged for a -debug verbosity:
+// 0 = errors/warnings (and notices), 1 = run info, 2 = detail, 5 = status
+func LevelForVerbosity(verbosity int) slog.Level {
+	switch {
+	case verbosity >= 5:
+		return LevelStatus
+	case verbosity >= 2:
+		return LevelDetail
+	case verbosity >= 1:
+		return LevelInfo
+	}
+
+	return LevelNotice
+}
+
+// New creates a Logger writing to w in the given format ("text" or "json"),
+// including only events at or above the level for the given verbosity
+func New(w io.Writer, format string, verbosity int) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{
+		Level: LevelForVerbosity(verbosity),
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key != slog.LevelKey || len(groups) > 0 {
+				return attr
+			}
+
+			if name, ok := levelNames[attr.Value.Any().(slog.Level)]; ok {
+				attr.Value = slog.StringValue(name)
+			}
+
+			return attr
+		},
+	}
+
+	switch format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJson:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("Unknown log format '%s'", format)
+}
+
+// Event logs an event of the given type, with any additional key/value pairs
+func Event(logger *slog.Logger, level slog.Level, event string, message string, args ...interface{}) {
+	logger.Log(context.Background(), level, message, append([]interface{}{KeyEvent, event}, args...)...)
+}
+
+// Fatal logs an error event of the given type, then exits
+func Fatal(logger *slog.Logger, event string, message string, args ...interface{}) {
+	Event(logger, LevelError, event, message, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	t.Run("JSON events should include stable fields and level names", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger, err := New(buf, FormatJson, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error creating logger: %s", err)
+		}
+
+		logger.Log(context.Background(), LevelNotice, "Pausing", KeyEvent, "paused")
+
+		var event map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+			t.Fatalf("Event was not valid JSON: %s", buf.String())
+		}
+
+		if event["level"] != "NOTICE" || event[KeyEvent] != "paused" {
+			t.Fatalf("Event did not include the expected fields: %s", buf.String())
+		}
+	})
+
+	t.Run("Verbosity should select the minimum level", func(t *testing.T) {
+		for verbosity, expected := range map[int][]string{
+			0: []string{"WARN", "NOTICE"},
+			1: []string{"WARN", "NOTICE", "INFO"},
+			2: []string{"WARN", "NOTICE", "INFO", "DETAIL"},
+			5: []string{"WARN", "NOTICE", "INFO", "DETAIL", "STATUS"},
+		} {
+			buf := new(bytes.Buffer)
+			logger, _ := New(buf, FormatText, verbosity)
+			logger.Log(context.Background(), LevelWarn, "warn")
+			logger.Log(context.Background(), LevelNotice, "notice")
+			logger.Log(context.Background(), LevelInfo, "info")
+			logger.Log(context.Background(), LevelDetail, "detail")
+			logger.Log(context.Background(), LevelStatus, "status")
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != len(expected) {
+				t.Fatalf("Verbosity %d logged %d events, expected %d:\n%s",
+					verbosity, len(lines), len(expected), buf.String())
+			}
+
+			for i, level := range expected {
+				if !strings.Contains(lines[i], "level="+level) {
+					t.Fatalf("Verbosity %d did not log %s as expected:\n%s", verbosity, level, buf.String())
+				}
+			}
+		}
+	})
+
+	t.Run("Unknown formats should be rejected", func(t *testing.T) {
+		if _, err := New(new(bytes.Buffer), "xml", 0); err == nil {
+			t.Fatalf("Unknown format was not rejected")
+		}
+	})
+}
@@ -1,37 +1,218 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/wpalmer/ecscron/control"
+	"github.com/wpalmer/ecscron/deadman"
+	"github.com/wpalmer/ecscron/events"
+	"github.com/wpalmer/ecscron/history"
+	"github.com/wpalmer/ecscron/leader"
+	"github.com/wpalmer/ecscron/logging"
+	"github.com/wpalmer/ecscron/metrics"
 	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/schedule/calendar"
 	"github.com/wpalmer/ecscron/schedule/crontab"
+	"github.com/wpalmer/ecscron/schedule/dependency"
+	"github.com/wpalmer/ecscron/schedule/maintenance"
+	"github.com/wpalmer/ecscron/schedule/mutex"
 	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/state"
 	"github.com/wpalmer/ecscron/taskrunner"
 	"github.com/wpalmer/ecscron/taskrunner/ecstaskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/pause"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
 	"github.com/wpalmer/ecscron/taskrunner/tweak"
 )
 
-const (
-	DEBUG_ERROR  = 0
-	DEBUG_INFO   = 1
-	DEBUG_DETAIL = 2
-	DEBUG_STATUS = 5
-)
-
 type simulatedStatus struct {
 	TaskName string
 }
 
+// namedPaths collects repeated "name=path" flags
+type namedPaths map[string]string
+
+func (p namedPaths) String() string {
+	pairs := []string{}
+	for name, path := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, path))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (p namedPaths) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=path, got '%s'", value)
+	}
+
+	p[parts[0]] = parts[1]
+	return nil
+}
+
+// healthcheck requests the health endpoint of a running ecscron, for use as a
+// container HEALTHCHECK command, returning the exit code
+func healthcheck(args []string) int {
+	var listen string
+	var path string
+	var timeout time.Duration
+
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	flags.StringVar(&listen, "listen", ":8080", "The address on which the running ecscron serves its HTTP API")
+	flags.StringVar(&path, "path", "/healthz", "The health endpoint to check, eg: '/readyz'")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "How long to wait for a response")
+	flags.Parse(args)
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid address '%s': %s\n", listen, err)
+		return 1
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, port), path)
+	if err := control.CheckURL(url, timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "Unhealthy: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// queryHistory prints the run history recorded by -history, returning the
+// exit code
+func queryHistory(args []string) int {
+	var historyPath string
+	var task string
+	var from string
+	var until string
+	var timezone string
+	var format string
+
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	flags.StringVar(&historyPath, "history", "/var/lib/ecscron/history.jsonl", "The run history file, as given to -history")
+	flags.StringVar(&task, "task", "", "Only show runs of tasks matching this pattern eg: 'billing-*'")
+	flags.StringVar(&from, "from", "", "Only show runs scheduled at or after this time, in YYYY-MM-DD[ HH:mm:ss] format")
+	flags.StringVar(&until, "until", "", "Only show runs scheduled before this time, in YYYY-MM-DD[ HH:mm:ss] format")
+	flags.StringVar(&timezone, "timezone", "UTC", "The TimeZone in which to evaluate and display times")
+	flags.StringVar(&format, "format", "text", "The output format, either 'text' or 'json'")
+	flags.Parse(args)
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse timezone: %s\n", err)
+		return 2
+	}
+
+	parse := func(value string) (time.Time, error) {
+		if value == "" {
+			return time.Time{}, nil
+		}
+
+		if parsed, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+			return parsed, nil
+		}
+
+		return time.ParseInLocation("2006-01-02 15:04:05", value, location)
+	}
+
+	filter := history.Filter{Task: task}
+	if filter.From, err = parse(from); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse -from: %s\n", err)
+		return 2
+	}
+
+	if filter.Until, err = parse(until); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse -until: %s\n", err)
+		return 2
+	}
+
+	if until != "" && len(until) == len("2006-01-02") {
+		// a date-only -until includes the whole of that day
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	records, err := history.Query(historyPath, filter, func(err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read history: %s\n", err)
+		return 1
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			_ = encoder.Encode(record)
+		}
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "SCHEDULED\tLAUNCHED\tTASK\tOUTCOME\tATTEMPT\tDETAIL\n")
+		for _, record := range records {
+			scheduled := "-"
+			if record.Scheduled != nil {
+				scheduled = record.Scheduled.In(location).Format("2006-01-02 15:04:05")
+			}
+
+			attempt := "-"
+			if record.Attempt > 0 {
+				attempt = fmt.Sprintf("%d", record.Attempt)
+			}
+
+			detail := strings.Join(record.TaskArns, ",")
+			if record.Outcome != history.OutcomeLaunched {
+				messages := record.Warnings
+				if record.Error != "" {
+					messages = append([]string{record.Error}, messages...)
+				}
+				detail = strings.Join(messages, "; ")
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", scheduled,
+				record.Launched.In(location).Format("2006-01-02 15:04:05"),
+				record.Task, record.Outcome, attempt, detail)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", format)
+		return 2
+	}
+
+	return 0
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "healthcheck":
+			os.Exit(healthcheck(os.Args[2:]))
+		case "history":
+			os.Exit(queryHistory(os.Args[2:]))
+		}
+	}
+
 	var async string
 	var doPause bool
 	var maxPause string
@@ -42,12 +223,43 @@ func main() {
 	var cluster string
 	var prefix string
 	var suffix string
+	var taskTemplate string
+	var taskMapPath string
 	var region string
 	var filePath string
+	var maintenancePath string
+	var webhooksPath string
+	var historyPath string
+	var historyMaxSize int64
+	var historyMaxFiles int
+	var deadmanGrace time.Duration
+	var pauseFilePath string
+	var stateFilePath string
+	var shutdownTimeout time.Duration
+	var leaderFilePath string
+	var leaderTable string
+	var leaderEndpoint string
+	var leaderID string
+	var leaderLease time.Duration
+	var splay time.Duration
+	var spread time.Duration
+	var shardIndex int
+	var concurrency int
+	var stopOnCapacity bool
+	var apiRate float64
+	var apiBurst int
+	var shardCount int
 	var doRetry bool
 	var retryCount int64
 	var simulate bool
 	var verbosity int
+	var logFormat string
+	var listen string
+	var healthGrace time.Duration
+	var healthAPIFailures time.Duration
+	var doValidate bool
+	calendarPaths := make(namedPaths)
+	parameterPaths := make(namedPaths)
 
 	var doDump bool
 	var dumpFrom string
@@ -66,10 +278,41 @@ func main() {
 	flag.StringVar(&cluster, "cluster", "", "The ECS Cluster on which to run tasks")
 	flag.StringVar(&region, "region", "", "The AWS Region in which the ECS Cluster resides")
 	flag.StringVar(&filePath, "crontab", "/etc/ecscrontab", "The location of the crontab file to parse")
+	flag.StringVar(&maintenancePath, "maintenance", "", "An optional file of maintenance windows, during which matching tasks are not run")
+	flag.StringVar(&historyPath, "history", "", "An optional file in which to record the result of every run, for the 'history' command")
+	flag.Int64Var(&historyMaxSize, "history-max-size", 10*1024*1024, "The size in bytes at which the -history file is rotated")
+	flag.IntVar(&historyMaxFiles, "history-max-files", 5, "The number of -history files to keep, including the current one")
+	flag.DurationVar(&deadmanGrace, "deadman-grace", 0, "Report any task which has not successfully launched within this long after it was expected (0 to disable)")
+	flag.StringVar(&pauseFilePath, "pause-file", "", "An optional control file of tasks to pause, one glob per line, re-read whenever it changes")
+	flag.StringVar(&stateFilePath, "state-file", "", "An optional file in which to persist runtime state (eg: paused tasks) across restarts")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "On SIGTERM or SIGINT, how long to wait for a tick in progress to finish before exiting anyway")
+	flag.StringVar(&leaderFilePath, "leader-file", "", "Only run while holding an exclusive lock on this file, for standby replicas on a single host")
+	flag.StringVar(&leaderTable, "leader-dynamodb-table", "", "Only run while holding a lease in this DynamoDB table (with string partition key 'lock_id'), for standby replicas on any host")
+	flag.StringVar(&leaderEndpoint, "leader-dynamodb-endpoint", "", "An optional endpoint URL for DynamoDB, eg: a local DynamoDB for testing")
+	flag.StringVar(&leaderID, "leader-id", "ecscron", "The name of the leader lock, shared by all replicas of the same cron")
+	flag.DurationVar(&leaderLease, "leader-lease", 30*time.Second, "How long a -leader-dynamodb-table lease lasts without renewal, after which a standby takes over")
+	flag.StringVar(&webhooksPath, "webhooks", "", "An optional file of webhooks to notify of events, such as failures")
+	flag.DurationVar(&splay, "splay", 0, "Delay each run by a random (but consistent) amount up to this duration eg: '30s', unless overridden by jitter=")
+	flag.IntVar(&concurrency, "concurrency", 1, "How many tasks may be launched at once within a tick (1 launches tasks one after another)")
+	flag.Float64Var(&apiRate, "api-rate", 0, "The maximum average rate of ECS API calls per second, eg: to avoid throttling during a large catch-up (0 for no limit)")
+	flag.IntVar(&apiBurst, "api-burst", 10, "How many ECS API calls may be made at once, before -api-rate applies")
+	flag.BoolVar(&stopOnCapacity, "stop-on-capacity-failure", false, "Once ECS cannot place a task for lack of capacity, skip any lower-priority tasks for the rest of the tick")
+	flag.IntVar(&shardIndex, "shard-index", 0, "Which of the -shard-count shards this instance is, from 0, running only the tasks assigned to it")
+	flag.IntVar(&shardCount, "shard-count", 1, "The number of ecscron instances sharing the crontab, each with a different -shard-index")
+	flag.DurationVar(&spread, "spread", 0, "Start the tasks due at each tick evenly across this window eg: '20s', rather than all at once")
 	flag.StringVar(&prefix, "prefix", "", "An optional prefix to add to all ECS Task names within the crontab")
 	flag.StringVar(&suffix, "suffix", "", "An optional suffix to add to all ECS Task names within the crontab")
+	flag.StringVar(&taskTemplate, "task-template", "", "An optional Go text/template giving the ECS Task name of each task within the crontab, eg: '{{.Env.ENV}}-{{.Task}}'")
+	flag.StringVar(&taskMapPath, "task-map", "", "An optional file mapping tasks within the crontab to ECS Task definitions, as '<task> <family>[:<revision>]' per line")
 	flag.BoolVar(&simulate, "simulate", false, "When true, don't actually run anything, only print what would be run")
 	flag.IntVar(&verbosity, "debug", 0, "Debug level 0 = errors/warnings, 1 = run info, 2 = detail, 5 = status")
+	flag.StringVar(&logFormat, "log-format", "text", "The format of log output, either 'text' or 'json'")
+	flag.Var(calendarPaths, "calendar", "A named calendar of blackout dates, as name=path to an iCalendar or date-list file (may be repeated)")
+	flag.Var(parameterPaths, "param", "The values of a parameter of templated entries, as name=path to a file of one value per line (may be repeated)")
+	flag.StringVar(&listen, "listen", "", "An optional address (eg: ':8080') on which to serve the HTTP status and control API")
+	flag.DurationVar(&healthGrace, "health-grace", 5*time.Minute, "How long past an expected tick before /healthz reports the scheduler as wedged")
+	flag.DurationVar(&healthAPIFailures, "health-api-failures", 10*time.Minute, "How long ECS API calls may fail continuously before /healthz reports unhealthy (0 to ignore)")
+	flag.BoolVar(&doValidate, "validate", false, "Rather than running the cron, check the crontab and report any warnings")
 
 	flag.BoolVar(&doDump, "dump", false, "Rather than running the cron, output a summary of the schedule")
 	flag.StringVar(&dumpFrom, "dump-from", "", "Output the schedule up starting from the specified time, in YYYY-MM-DD HH:mm:ss format")
@@ -77,15 +320,23 @@ func main() {
 	flag.StringVar(&dumpFormat, "dump-format", "", "Output the schedule in the specified format. Currently the only supported format is 'json'")
 	flag.Parse()
 
+	logger, err := logging.New(os.Stderr, logFormat, verbosity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
 	location, err := time.LoadLocation(timezone)
 	if err != nil {
-		log.Fatalf("Failed to parse timzeone: %s", err)
+		logging.Fatal(logger, "invalid_arguments", "Failed to parse timezone",
+			logging.KeyError, err)
 	}
 
 	if async != "" {
 		prevTick, err = time.ParseInLocation("2006-01-02 15:04:05", async, location)
 		if err != nil {
-			log.Fatalf("Failed to parse time of last run: %s", err)
+			logging.Fatal(logger, "invalid_arguments", "Failed to parse time of last run",
+				logging.KeyError, err)
 		}
 
 		first = false
@@ -94,40 +345,193 @@ func main() {
 	if maxPause != "" {
 		maxPauseDuration, err = time.ParseDuration(maxPause)
 		if err != nil {
-			log.Fatalf("Failed to parse maximum pause duration: %s", err)
+			logging.Fatal(logger, "invalid_arguments", "Failed to parse maximum pause duration",
+				logging.KeyError, err)
 		}
 	}
 
+	var stats *metrics.Metrics
+	var health *control.Health
+	if listen != "" {
+		stats = metrics.New()
+		health = control.NewHealth(healthGrace, healthAPIFailures)
+	}
+
 	if doRetry && retryCount == int64(0) {
 		retryCount = -1
 	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalf("Error opening crontab: %s", err)
+		logging.Fatal(logger, "crontab_error", "Error opening crontab",
+			"path", filePath, logging.KeyError, err)
 	}
 
 	var sched schedule.Schedule
+	if spread < 0 {
+		logging.Fatal(logger, "invalid_arguments", "-spread must not be negative",
+			"spread", spread.String())
+	}
+
+	if concurrency < 1 {
+		logging.Fatal(logger, "invalid_arguments", "-concurrency must be at least 1",
+			"concurrency", concurrency)
+	}
+
 	table := crontab.NewCrontab()
+	table.SetLocation(location)
+	table.SetSplay(splay)
+	table.SetConcurrency(concurrency)
+	for name, path := range calendarPaths {
+		calendarFile, err := os.Open(path)
+		if err != nil {
+			logging.Fatal(logger, "calendar_error", "Error opening calendar",
+				"calendar", name, "path", path, logging.KeyError, err)
+		}
+
+		cal, err := calendar.Load(calendarFile)
+		calendarFile.Close()
+		if err != nil {
+			logging.Fatal(logger, "calendar_error", "Error loading calendar",
+				"calendar", name, "path", path, logging.KeyError, err)
+		}
+
+		table.SetCalendar(name, cal)
+	}
+
+	for name, path := range parameterPaths {
+		parameterFile, err := os.Open(path)
+		if err != nil {
+			logging.Fatal(logger, "parameter_error", "Error opening parameter values",
+				"parameter", name, "path", path, logging.KeyError, err)
+		}
+
+		err = table.LoadParameter(name, parameterFile)
+		parameterFile.Close()
+		if err != nil {
+			logging.Fatal(logger, "parameter_error", "Error loading parameter values",
+				"parameter", name, "path", path, logging.KeyError, err)
+		}
+	}
+
 	if ok, err := table.Load(file); !ok {
-		log.Fatalf("Error loading crontab: %s", err)
+		logging.Fatal(logger, "crontab_error", "Error loading crontab",
+			"path", filePath, logging.KeyError, err)
 	}
 	sched = table
 
-	if retryCount != 0 {
-		numAttempts := retryCount
-		if numAttempts > 0 {
-			numAttempts += 1
+	// the tasks run by this instance
+	var owned schedule.TaskLookup = table
+	var chains dependency.Lookup = table
+	var base schedule.Schedule = table
+	if shardCount < 1 || shardIndex < 0 || shardIndex >= shardCount {
+		logging.Fatal(logger, "invalid_arguments", "-shard-index must be from 0 to less than -shard-count",
+			"shard_index", shardIndex, "shard_count", shardCount)
+	}
+
+	if shardCount > 1 {
+		sharded := schedule.NewShardedSchedule(table, shardIndex, shardCount)
+		sharded.SetConcurrency(concurrency)
+		owned = sharded
+		chains = sharded
+		base = sharded
+		sched = sharded
+		logging.Event(logger, logging.LevelNotice, "sharded", "Running only the tasks assigned to this shard",
+			"shard_index", shardIndex, "shard_count", shardCount, "tasks", len(sharded.Tasks()))
+	}
+
+	if doValidate {
+		warnings := table.Validate(time.Now().In(location))
+		for _, warning := range warnings {
+			logging.Event(logger, logging.LevelWarn, "crontab_warning", "Warning in crontab",
+				"path", filePath, logging.KeyError, warning)
+		}
+
+		if len(warnings) == 0 {
+			logging.Event(logger, logging.LevelInfo, "crontab_valid", "Crontab is valid",
+				"path", filePath)
 		}
+		os.Exit(0)
+	}
 
-		sched = retry.NewRetrySchedule(sched, numAttempts)
+	// whether a task is running, to check its mutex groups
+	isRunning := func(task string) (bool, error) {
+		return false, nil
+	}
+
+	// follows launched tasks to completion, to run their dependents
+	var tracker dependency.Tracker = dependency.NewImmediateTracker()
+
+	// the name of the task definition run for each task
+	var translate tweak.Translator = func(task string) string {
+		return fmt.Sprintf("%s%s%s", prefix, task, suffix)
+	}
+
+	if taskTemplate != "" {
+		if prefix != "" || suffix != "" {
+			logging.Fatal(logger, "invalid_arguments", "-task-template cannot be combined with -prefix or -suffix")
+		}
+
+		env := make(map[string]string)
+		for _, pair := range os.Environ() {
+			parts := strings.SplitN(pair, "=", 2)
+			env[parts[0]] = parts[1]
+		}
+
+		translate, err = tweak.NewTemplateTranslator(taskTemplate, table.Tasks(), table, env)
+		if err != nil {
+			logging.Fatal(logger, "invalid_arguments", "Invalid -task-template",
+				logging.KeyError, err)
+		}
+	}
+
+	if taskMapPath != "" {
+		mapFile, err := os.Open(taskMapPath)
+		if err != nil {
+			logging.Fatal(logger, "task_map_error", "Error opening task map",
+				"path", taskMapPath, logging.KeyError, err)
+		}
+
+		mapping, err := tweak.LoadMapping(mapFile)
+		mapFile.Close()
+		if err != nil {
+			logging.Fatal(logger, "task_map_error", "Error loading task map",
+				"path", taskMapPath, logging.KeyError, err)
+		}
+
+		for task := range mapping {
+			if _, ok := table.Nexter(task); !ok {
+				logging.Fatal(logger, "task_map_error", "Task map names a task which is not in the crontab",
+					"path", taskMapPath, logging.KeyTask, task)
+			}
+		}
+
+		translate = tweak.Mapped(mapping, translate)
+	}
+
+	// the variables of tasks expanded from templated entries, by task (not
+	// task definition, which several tasks may share)
+	environments := ecstaskrunner.EnvironmentMap{}
+	for _, task := range table.Tasks() {
+		if environment := table.Environment(task); len(environment) > 0 {
+			environments[task] = environment
+		}
 	}
 
 	var runner taskrunner.TaskRunner
 	if simulate {
-		runner = taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
-			log.Printf("[-simulate] Running: %s", task)
-			return &taskrunner.TaskStatus{Ran: true, Output: &simulatedStatus{TaskName: task}}, nil
+		runner = taskrunner.DefinitionTaskRunnerFunc(func(task string, definition string) (*taskrunner.TaskStatus, error) {
+			fields := []interface{}{logging.KeyTask, task}
+			if definition != task {
+				fields = append(fields, "definition", definition)
+			}
+
+			if environment := environments.Environment(task); len(environment) > 0 {
+				fields = append(fields, "environment", environment)
+			}
+
+			logging.Event(logger, logging.LevelInfo, "task_simulated", "[-simulate] Running", fields...)
+			return &taskrunner.TaskStatus{Ran: true, Output: &simulatedStatus{TaskName: definition}}, nil
 		})
 	} else {
 		awsConfig := aws.NewConfig()
@@ -136,23 +540,117 @@ func main() {
 		}
 
 		awsSession := session.Must(session.NewSession(awsConfig))
-		ecsService := ecs.New(awsSession)
+		var ecsService ecstaskrunner.MinimalECSAPI = ecs.New(awsSession)
+		if stats != nil {
+			ecsService = ecstaskrunner.NewObservedService(ecsService,
+				func(operation string, duration time.Duration, err error) {
+					stats.ObserveAPI(operation, duration, err)
+					health.ObserveAPI(operation, duration, err)
+				})
+		}
 
+		// limiting is outermost, so that every actual call is observed
+		var bucket *ecstaskrunner.TokenBucket
+		if apiRate > 0 {
+			bucket = ecstaskrunner.NewTokenBucket(apiRate, apiBurst)
+		}
+		ecsService = ecstaskrunner.NewRateLimitedService(ecsService, bucket)
+
+		// tasks are started by their task definition, as before definitions
+		// could be shared, unless several tasks share it
+		identify := ecstaskrunner.Identifier(tweak.Identify(table.Tasks(), translate))
 		innerRunner := ecstaskrunner.NewEcsTaskRunner(ecsService, cluster)
-		runner = ecstaskrunner.NewEcsSkipRunningTaskRunner(ecsService, cluster, innerRunner)
+		innerRunner.SetEnvironment(environments, ecsService)
+		innerRunner.SetIdentifier(identify)
+		skipper := ecstaskrunner.NewEcsSkipRunningTaskRunner(ecsService, cluster, innerRunner)
+		skipper.SetIdentifier(identify)
+		isRunning = skipper.IsRunning
+		runner = skipper
+		tracker = ecstaskrunner.NewCompletionTracker(ecsService, cluster)
 	}
 
-	if prefix != "" || suffix != "" {
-		runner = tweak.NewTweakTaskRunner(runner, func(task string) string {
-			return fmt.Sprintf("%s%s%s", prefix, task, suffix)
+	if prefix != "" || suffix != "" || taskTemplate != "" || taskMapPath != "" {
+		// the ECS runners are given both the task and its task definition,
+		// so that tasks sharing a definition are told apart
+		runner = tweak.NewTweakTaskRunner(runner, translate)
+	}
+
+	// innermost, so that dependents are retried, and are subject to
+	// maintenance windows and mutex groups, as any other run
+	dependencies := dependency.NewDependencySchedule(sched, chains, tracker, func(err error) {
+		logging.Event(logger, logging.LevelWarn, "dependency_check_failed", "Failed to check for completed tasks, will check again",
+			logging.KeyError, err)
+	})
+	sched = dependencies
+
+	// once the leader lock is lost, another replica may already be running,
+	// so exit immediately, without saving state over theirs. This is checked
+	// before every launch, as well as between ticks, as the lock may be lost
+	// part-way through a tick.
+	var leadershipLost <-chan struct{}
+	abdicate := func() {
+		logging.Fatal(logger, "leadership_lost", "Lost leader lock, exiting",
+			"leader_id", leaderID)
+	}
+
+	if leaderFilePath != "" || leaderTable != "" {
+		unguarded := runner
+		runner = taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			select {
+			case <-leadershipLost:
+				abdicate()
+			default:
+			}
+
+			return unguarded.RunTask(task)
 		})
 	}
 
+	// every launch, however it is made, may have dependents to follow it
+	runner = dependencies.Wrap(runner)
+
+	var retrySchedule *retry.RetrySchedule
+	if retryCount != 0 {
+		numAttempts := retryCount
+		if numAttempts > 0 {
+			numAttempts += 1
+		}
+
+		retrySchedule = retry.NewRetrySchedule(sched, numAttempts)
+		retrySchedule.SetConcurrency(concurrency)
+		sched = retrySchedule
+	}
+
+	var windows []*maintenance.Window
+
+	if maintenancePath != "" {
+		maintenanceFile, err := os.Open(maintenancePath)
+		if err != nil {
+			logging.Fatal(logger, "maintenance_error", "Error opening maintenance windows",
+				"path", maintenancePath, logging.KeyError, err)
+		}
+
+		windows, err = maintenance.Load(maintenanceFile, location)
+		maintenanceFile.Close()
+		if err != nil {
+			logging.Fatal(logger, "maintenance_error", "Error loading maintenance windows",
+				"path", maintenancePath, logging.KeyError, err)
+		}
+
+		sched = maintenance.NewMaintenanceSchedule(sched, windows, table)
+	}
+
+	// outermost, so that retries and catch-ups are also excluded
+	unexcluded := sched
+	mutexes := mutex.NewMutexSchedule(sched, table, isRunning)
+	sched = mutexes
+
 	if dumpFrom != "" {
 		doDump = true
 		dumpFromTime, err = time.ParseInLocation("2006-01-02 15:04:05", dumpFrom, location)
 		if err != nil {
-			log.Fatalf("Failed to parse time to dump from: %s", err)
+			logging.Fatal(logger, "invalid_arguments", "Failed to parse time to dump from",
+				logging.KeyError, err)
 		}
 	} else {
 		dumpFromTime = time.Now()
@@ -162,7 +660,8 @@ func main() {
 		doDump = true
 		dumpUntilTime, err = time.ParseInLocation("2006-01-02 15:04:05", dumpUntil, location)
 		if err != nil {
-			log.Fatalf("Failed to parse time to dump until: %s", err)
+			logging.Fatal(logger, "invalid_arguments", "Failed to parse time to dump until",
+				logging.KeyError, err)
 		}
 	} else {
 		dumpUntilTime = dumpFromTime.Add(time.Hour * 24)
@@ -173,135 +672,759 @@ func main() {
 	} else {
 		doDump = true
 		if dumpFormat != "json" {
-			log.Fatalf("Unknown dump format: %s", dumpFormat)
+			logging.Fatal(logger, "invalid_arguments", "Unknown dump format",
+				"format", dumpFormat)
 		}
 	}
 
 	if doDump {
-		_, err := schedule.DumpJson(os.Stdout, sched, dumpFromTime.Add(-1), dumpUntilTime)
+		// nothing is running in a dump, which should not depend on ECS
+		dumped := mutex.NewMutexSchedule(unexcluded, table, func(task string) (bool, error) {
+			return false, nil
+		})
+
+		_, err := schedule.DumpJsonChained(os.Stdout, dumped, chains, dumpFromTime.Add(-1), dumpUntilTime)
 		if err != nil {
-			log.Fatalf("Failed to dump schedule: %s", err)
+			logging.Fatal(logger, "dump_error", "Failed to dump schedule",
+				logging.KeyError, err)
 		}
 
 		fmt.Printf("\n")
 		os.Exit(0)
 	}
 
+	var server *control.Server
+	var requests <-chan *control.Request
+	if listen != "" {
+		server = control.NewServer(func() schedule.Schedule {
+			// a fresh, unshared, view of the schedule, so that planning does
+			// not disturb the state of the running schedule
+			if windows == nil {
+				return base
+			}
+
+			return maintenance.NewMaintenanceSchedule(base, windows, table)
+		}, location)
+		requests = server.Requests()
+		server.Handle("/metrics", stats.Registry)
+		server.Handle("/healthz", http.HandlerFunc(health.ServeLiveness))
+		server.Handle("/readyz", http.HandlerFunc(health.ServeReadiness))
+
+		// served while a standby, so that it is seen to be alive, but
+		// refusing control requests until the leader lock is acquired, as
+		// nothing handles them until then
+		if leaderFilePath != "" || leaderTable != "" {
+			server.Update(func(status *control.Status) {
+				status.State = control.StateStandby
+			})
+			health.Standby()
+		}
+
+		go func() {
+			logging.Fatal(logger, "http_error", "Failed to serve HTTP API",
+				"listen", listen, logging.KeyError, http.ListenAndServe(listen, server))
+		}()
+	}
+
+	if leaderFilePath != "" && leaderTable != "" {
+		logging.Fatal(logger, "invalid_arguments", "Only one of -leader-file and -leader-dynamodb-table may be given")
+	}
+
+	var elector *leader.Elector
+	var lock leader.Lock
+	if leaderFilePath != "" || leaderTable != "" {
+		lock = leader.NewFileLock(leaderFilePath)
+		interval := time.Second
+		if leaderTable != "" {
+			hostname, _ := os.Hostname()
+			owner := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+
+			awsConfig := aws.NewConfig()
+			if region != "" {
+				awsConfig = awsConfig.WithRegion(region)
+			}
+			if leaderEndpoint != "" {
+				awsConfig = awsConfig.WithEndpoint(leaderEndpoint)
+			}
+
+			awsSession := session.Must(session.NewSession(awsConfig))
+			lock = leader.NewDynamoDBLock(dynamodb.New(awsSession), leaderTable, leaderID, owner, leaderLease)
+
+			// renew several times per lease, so that a few failed renewals
+			// do not lose it
+			interval = leaderLease / 3
+		}
+
+		elector = leader.NewElector(lock, interval, leaderLease, func(err error) {
+			logging.Event(logger, logging.LevelWarn, "leader_error", "Error acquiring or renewing leader lock",
+				logging.KeyError, err)
+		})
+
+		// a standby may be stopped at any time, there is nothing to clean up
+		stop := make(chan struct{})
+		campaignSignals := make(chan os.Signal, 1)
+		signal.Notify(campaignSignals, syscall.SIGTERM, syscall.SIGINT)
+		go func() {
+			if _, ok := <-campaignSignals; ok {
+				close(stop)
+			}
+		}()
+
+		logging.Event(logger, logging.LevelInfo, "leader_waiting", "Waiting to acquire leader lock",
+			"leader_id", leaderID)
+		if !elector.Campaign(stop) {
+			logging.Event(logger, logging.LevelNotice, "shutdown_complete", "Stopped while waiting to acquire leader lock")
+			os.Exit(0)
+		}
+
+		signal.Stop(campaignSignals)
+		close(campaignSignals)
+		logging.Event(logger, logging.LevelNotice, "leader_acquired", "Acquired leader lock, running",
+			"leader_id", leaderID)
+		leadershipLost = elector.Maintain(make(chan struct{}))
+
+		if server != nil {
+			server.Update(func(status *control.Status) {
+				status.State = control.StateRunning
+			})
+		}
+	}
+
+	// state is only loaded once leadership is acquired, so that a standby
+	// resumes from wherever the previous leader stopped
+	saved := &state.State{}
+	if stateFilePath != "" {
+		saved, err = state.Load(stateFilePath)
+		if err != nil {
+			logging.Fatal(logger, "state_error", "Error loading state",
+				"path", stateFilePath, logging.KeyError, err)
+		}
+
+		// resume from the last completed tick, as with -async
+		if async == "" && saved.LastTick != nil {
+			prevTick = saved.LastTick.In(location)
+			first = false
+			logging.Event(logger, logging.LevelNotice, "checkpoint_restored", "Resuming from the last completed tick",
+				"path", stateFilePath, logging.KeyScheduled, prevTick)
+		}
+	}
+
+	if retrySchedule != nil {
+		retrySchedule.Restore(saved.Retries)
+	}
+	mutexes.Restore(saved.Deferred)
+	dependencies.Restore(saved.Awaiting)
+	dependencies.RestoreSatisfied(saved.Satisfied)
+
 	if first {
 		prevTick = time.Now().In(location)
 	}
 
+	bus := events.NewBus()
+	var notifiers []*events.Notifier
+	if webhooksPath != "" {
+		webhooksFile, err := os.Open(webhooksPath)
+		if err != nil {
+			logging.Fatal(logger, "webhooks_error", "Error opening webhooks",
+				"path", webhooksPath, logging.KeyError, err)
+		}
+
+		webhooks, err := events.LoadWebhooks(webhooksFile)
+		webhooksFile.Close()
+		if err != nil {
+			logging.Fatal(logger, "webhooks_error", "Error loading webhooks",
+				"path", webhooksPath, logging.KeyError, err)
+		}
+
+		for _, webhook := range webhooks {
+			notifier := events.NewNotifier(webhook, logger)
+			notifiers = append(notifiers, notifier)
+			bus.Subscribe(notifier)
+		}
+	}
+
+	var store *history.Store
+	if historyPath != "" {
+		store = history.NewStore(historyPath, historyMaxSize, historyMaxFiles)
+	}
+
+	pauses := pause.NewSet()
+	if err := pauses.Restore(saved.PausedTasks); err != nil {
+		logging.Fatal(logger, "state_error", "Error restoring paused tasks",
+			"path", stateFilePath, logging.KeyError, err)
+	}
+
+	saveState := func() {
+		if stateFilePath == "" {
+			return
+		}
+
+		last := prevTick
+		current := &state.State{LastTick: &last, PausedTasks: pauses.Runtime(), Deferred: mutexes.Deferred(),
+			Awaiting: dependencies.Awaiting(), Satisfied: dependencies.Satisfied()}
+		if retrySchedule != nil {
+			current.Retries = retrySchedule.Pending()
+		}
+
+		if err := state.Save(stateFilePath, current); err != nil {
+			logging.Event(logger, logging.LevelError, "state_error", "Failed to save state",
+				"path", stateFilePath, logging.KeyError, err)
+		}
+	}
+
+	var pauseFileModified time.Time
+	reloadPauseFile := func() {
+		if pauseFilePath == "" {
+			return
+		}
+
+		info, err := os.Stat(pauseFilePath)
+		if os.IsNotExist(err) {
+			pauses.SetFile(nil)
+			pauseFileModified = time.Time{}
+			return
+		}
+
+		if err != nil || info.ModTime().Equal(pauseFileModified) {
+			return
+		}
+
+		pauseFile, err := os.Open(pauseFilePath)
+		if err != nil {
+			logging.Event(logger, logging.LevelError, "pause_file_error", "Failed to open pause file",
+				"path", pauseFilePath, logging.KeyError, err)
+			return
+		}
+
+		loaded, err := pause.LoadFile(pauseFile)
+		pauseFile.Close()
+		if err != nil {
+			logging.Event(logger, logging.LevelError, "pause_file_error", "Failed to load pause file, keeping the previous pauses",
+				"path", pauseFilePath, logging.KeyError, err)
+			return
+		}
+
+		pauseFileModified = info.ModTime()
+		pauses.SetFile(loaded)
+		logging.Event(logger, logging.LevelNotice, "pause_file_loaded", "Loaded paused tasks from pause file",
+			"path", pauseFilePath, "patterns", len(loaded))
+	}
+	reloadPauseFile()
+
+	var monitor *deadman.Monitor
+	if deadmanGrace > 0 {
+		monitor = deadman.NewMonitor(owned, deadmanGrace, prevTick)
+		if historyPath != "" {
+			records, err := history.Query(historyPath, history.Filter{}, func(err error) {
+				logging.Event(logger, logging.LevelWarn, "history_error", "Run history contains an unreadable record, which was skipped",
+					"path", historyPath, logging.KeyError, err)
+			})
+			if err != nil {
+				logging.Event(logger, logging.LevelWarn, "history_error", "Failed to read run history, for dead-man detection",
+					"path", historyPath, logging.KeyError, err)
+			}
+
+			monitor.Replay(records)
+		}
+	}
+
+	checkOverdue := func() {
+		if monitor == nil {
+			return
+		}
+
+		for _, overdue := range monitor.Check(time.Now().In(location)) {
+			logging.Event(logger, logging.LevelWarn, "task_overdue", "Task has not run successfully when expected",
+				logging.KeyTask, overdue.Task, "expected", overdue.Expected, "last_success", overdue.LastSuccess)
+
+			expected := overdue.Expected
+			bus.Publish(events.Event{
+				Type:      events.TaskOverdue,
+				Time:      time.Now().In(location),
+				Task:      overdue.Task,
+				Scheduled: &expected,
+				Message: fmt.Sprintf("No successful run since %s",
+					overdue.LastSuccess.In(location).Format("2006-01-02 15:04:05")),
+			})
+		}
+	}
+
+	publishResults := func(results map[string]*taskrunner.TaskStatus, scheduled time.Time, launched time.Time) {
+		for _, event := range events.FromResults(results, scheduled, time.Now().In(location)) {
+			bus.Publish(event)
+		}
+
+		if monitor != nil {
+			at := scheduled
+			if at.IsZero() {
+				at = launched
+			}
+			monitor.Observe(results, at)
+		}
+
+		if store == nil {
+			return
+		}
+
+		if err := store.Append(history.FromResults(results, scheduled, launched)); err != nil {
+			logging.Event(logger, logging.LevelError, "history_error", "Failed to record run history",
+				"path", historyPath, logging.KeyError, err)
+		}
+	}
+
 	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	// SIGTERM and SIGINT are noticed immediately, so that the shutdown
+	// timeout applies even to a tick in progress, but are only acted upon
+	// between ticks
+	terminate := make(chan os.Signal, 2)
+	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
+	terminating := make(chan os.Signal, 1)
+
+	// set before a signal is passed to terminating, by when shutdown must be
+	// complete
+	var shutdownDeadline time.Time
+	go func() {
+		received := <-terminate
+		logging.Event(logger, logging.LevelNotice, "shutdown", "Received signal, shutting down after any tick in progress",
+			"signal", received.String(), "timeout", shutdownTimeout.String())
+		shutdownDeadline = time.Now().Add(shutdownTimeout)
+		time.AfterFunc(shutdownTimeout, func() {
+			logging.Fatal(logger, "shutdown_timeout", "Shutdown timeout exceeded, exiting",
+				"timeout", shutdownTimeout.String())
+		})
+		terminating <- received
+
+		received = <-terminate
+		logging.Fatal(logger, "shutdown_timeout", "Received a second signal during shutdown, exiting",
+			"signal", received.String())
+	}()
+
+	shutdown := func() {
+		saveState()
+
+		// undelivered notifications are abandoned a little before the
+		// shutdown timeout, so that the leader lock can still be released
+		deadline := shutdownDeadline.Add(-time.Second)
+		var closing sync.WaitGroup
+		for _, notifier := range notifiers {
+			closing.Add(1)
+			go func(notifier *events.Notifier) {
+				defer closing.Done()
+				notifier.Close(deadline)
+			}(notifier)
+		}
+		closing.Wait()
+
+		if lock != nil {
+			if err := lock.Release(); err != nil {
+				logging.Event(logger, logging.LevelWarn, "leader_error", "Failed to release leader lock",
+					logging.KeyError, err)
+			}
+		}
+
+		logging.Event(logger, logging.LevelNotice, "shutdown_complete", "Shut down cleanly")
+		os.Exit(0)
+	}
+
+	// checkTerminating shuts down if a signal has been received, or leadership
+	// has been lost, ensuring that no new tick is started
+	checkTerminating := func() {
+		select {
+		case <-leadershipLost:
+			abdicate()
+		case <-terminating:
+			shutdown()
+		default:
+		}
+	}
+
+	paused := false
+	var pausedUntil time.Time
+	var pauseTimer *time.Timer
+
+	stopPauseTimer := func() {
+		if pauseTimer != nil {
+			pauseTimer.Stop()
+			pauseTimer = nil
+		}
+		pausedUntil = time.Time{}
+	}
+
+	pauseFor := func(duration time.Duration) {
+		stopPauseTimer()
+		paused = true
+		message := "Paused"
+		if duration > 0 {
+			pauseTimer = time.NewTimer(duration)
+			pausedUntil = time.Now().In(location).Add(duration)
+			message = fmt.Sprintf("Paused until %s", pausedUntil.Format("2006-01-02 15:04:05"))
+		}
+
+		bus.Publish(events.Event{Type: events.Paused, Time: time.Now().In(location), Message: message})
+	}
+
+	resume := func() {
+		stopPauseTimer()
+		if paused {
+			bus.Publish(events.Event{Type: events.Resumed, Time: time.Now().In(location), Message: "Resumed"})
+		}
+		paused = false
+	}
+
+	publish := func() {
+		if stats != nil {
+			stats.SetPaused(paused)
+			stats.SetNextTick(nextTick)
+		}
+
+		if health != nil {
+			// while paused, no tick is expected until the pause ends
+			expected := nextTick
+			if paused {
+				expected = pausedUntil
+			}
+			health.Expect(expected)
+		}
+
+		if server == nil {
+			return
+		}
+
+		server.Update(func(status *control.Status) {
+			status.State = control.StateRunning
+			status.PausedUntil = nil
+			if paused {
+				status.State = control.StatePaused
+				if !pausedUntil.IsZero() {
+					until := pausedUntil
+					status.PausedUntil = &until
+				}
+			}
+
+			status.LastTick = nil
+			if !first {
+				last := prevTick
+				status.LastTick = &last
+			}
+
+			status.NextTick = nil
+			if !nextTick.IsZero() {
+				next := nextTick
+				status.NextTick = &next
+			}
+
+			if retrySchedule != nil {
+				status.Retries = retrySchedule.Pending()
+				status.MaxRetries = retrySchedule.MaxRetries()
+			}
+
+			status.PausedTasks = pauses.Active(time.Now().In(location))
+		})
+	}
+
+	handleRequest := func(request *control.Request) *control.Response {
+		switch request.Action {
+		case control.Pause:
+			duration := request.Duration
+			if duration == 0 {
+				duration = maxPauseDuration
+			}
+
+			logging.Event(logger, logging.LevelNotice, "paused", "Received pause request via HTTP API, pausing",
+				"source", "http", "duration", duration.String())
+			pauseFor(duration)
+		case control.Resume:
+			if paused {
+				logging.Event(logger, logging.LevelNotice, "resumed", "Received resume request via HTTP API, resuming",
+					"source", "http")
+				resume()
+			}
+		case control.PauseTask:
+			var until *time.Time
+			if request.Duration > 0 {
+				end := time.Now().In(location).Add(request.Duration)
+				until = &end
+			}
+
+			if err := pauses.Pause(request.Task, until, request.Reason); err != nil {
+				return &control.Response{Error: err}
+			}
+
+			logging.Event(logger, logging.LevelNotice, "task_paused", "Received request via HTTP API to pause tasks",
+				"source", "http", "pattern", request.Task, "duration", request.Duration.String(), "reason", request.Reason)
+			bus.Publish(events.Event{Type: events.Paused, Time: time.Now().In(location), Task: request.Task,
+				Message: "Tasks paused"})
+			saveState()
+		case control.ResumeTask:
+			if !pauses.Resume(request.Task) {
+				return &control.Response{Error: control.ErrUnknownTask}
+			}
+
+			logging.Event(logger, logging.LevelNotice, "task_resumed", "Received request via HTTP API to resume tasks",
+				"source", "http", "pattern", request.Task)
+			bus.Publish(events.Event{Type: events.Resumed, Time: time.Now().In(location), Task: request.Task,
+				Message: "Tasks resumed"})
+			saveState()
+		case control.RunTask:
+			known := false
+			for _, task := range owned.Tasks() {
+				if task == request.Task {
+					known = true
+					break
+				}
+			}
+
+			if !known {
+				return &control.Response{Error: control.ErrUnknownTask}
+			}
+
+			logging.Event(logger, logging.LevelInfo, "run_requested", "Received request via HTTP API to run task",
+				"source", "http", logging.KeyTask, request.Task)
+
+			launched := time.Now().In(location)
+			reloadPauseFile()
+			result, err := pauses.Wrap(mutexes.Wrap(runner), launched).RunTask(request.Task)
+			if err != nil {
+				logging.Event(logger, logging.LevelError, "task_error", "Error when running task via HTTP API",
+					"source", "http", logging.KeyTask, request.Task, logging.KeyCluster, cluster,
+					logging.KeyErrorClass, errorClass(err), logging.KeyError, err)
+				return &control.Response{Error: err}
+			}
+
+			results := map[string]*taskrunner.TaskStatus{request.Task: result}
+			if stats != nil {
+				stats.ObserveResults(results)
+			}
+
+			publishResults(results, time.Time{}, launched)
+			logResults(logger, results, time.Time{}, cluster)
+			return &control.Response{Status: result}
+		}
+
+		return &control.Response{}
+	}
+
 	if doPause {
-		signals <- syscall.SIGUSR1
+		logging.Event(logger, logging.LevelNotice, "paused", "Pausing, send SIGUSR1 to resume",
+			"source", "flag")
+		pauseFor(maxPauseDuration)
 	}
-	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGINT)
 
 	ticks := make(chan time.Time, 1)
 
+	// overdue tasks are also checked between ticks, as there may be long gaps
+	var overdueChecks <-chan time.Time
+	if monitor != nil {
+		overdueChecks = time.NewTicker(time.Minute).C
+	}
+
 	for {
+		checkTerminating()
 		nextTick = sched.Next(prevTick)
 		pause := nextTick.Sub(time.Now().In(location))
 		if pause < time.Duration(0) {
-			log.Printf("Cron tasks running slowly: %0.2f seconds late entering tick scheduled for %v",
-				(pause * time.Duration(-1)).Seconds(), nextTick)
+			logging.Event(logger, logging.LevelWarn, "tick_late", "Cron tasks running slowly",
+				logging.KeyScheduled, nextTick, logging.KeyLateness, (pause * time.Duration(-1)).Seconds())
 			ticks <- nextTick
 		} else {
-			if verbosity >= DEBUG_STATUS {
-				log.Printf("Sleeping for %0.2f seconds", pause.Seconds())
-			}
+			logging.Event(logger, logging.LevelStatus, "sleeping", "Sleeping until next tick",
+				logging.KeyScheduled, nextTick, "seconds", pause.Seconds())
 			go func() {
 				time.Sleep(pause)
 				ticks <- nextTick
 			}()
 		}
+		publish()
 
 		ticked := false
 		for ticked == false {
+			// while paused, any tick is held until resuming
+			var tickChannel <-chan time.Time = ticks
+			if paused {
+				tickChannel = nil
+			}
+
+			var pauseTimeout <-chan time.Time
+			if pauseTimer != nil {
+				pauseTimeout = pauseTimer.C
+			}
+
 			select {
-			case <-ticks:
+			case <-tickChannel:
 				ticked = true
-			case oneSignal := <-signals:
-				switch oneSignal {
-				case syscall.SIGINT:
-					log.Fatalf("Received SIGINT, exiting...")
-				case syscall.SIGUSR1:
-					if doPause {
-						doPause = false
-						log.Printf("Pausing, send SIGUSR1 to resume...")
-					} else {
-						log.Printf("Received SIGUSR1, pausing...")
-					}
-
-					var maxPauseChannel <-chan time.Time
-					var maxPauseTimer *time.Timer
-					if maxPause != "" {
-						maxPauseTimer = time.NewTimer(maxPauseDuration)
-						maxPauseChannel = maxPauseTimer.C
-					} else {
-						maxPauseChannel = make(<-chan time.Time, 0)
-					}
-
-					select {
-					case <-maxPauseChannel:
-						log.Printf("Maximum Pause Duration exceeded without receiving SIGUSR1, resuming...")
-					case oneSignal := <-signals:
-						maxPauseTimer.Stop()
-
-						switch oneSignal {
-						case syscall.SIGINT:
-							log.Fatalf("Received SIGINT while paused, exiting...")
-						case syscall.SIGUSR1:
-							log.Printf("Received SIGUSR1 while paused, resuming...")
-						}
-					}
+			case <-pauseTimeout:
+				pauseTimer = nil
+				logging.Event(logger, logging.LevelNotice, "resumed", "Maximum Pause Duration exceeded without being resumed, resuming",
+					"source", "max-pause")
+				resume()
+				publish()
+			case <-terminating:
+				shutdown()
+			case <-leadershipLost:
+				abdicate()
+			case <-signals:
+				if paused {
+					logging.Event(logger, logging.LevelNotice, "resumed", "Received SIGUSR1 while paused, resuming",
+						"source", "signal")
+					resume()
+				} else {
+					logging.Event(logger, logging.LevelNotice, "paused", "Received SIGUSR1, pausing",
+						"source", "signal")
+					pauseFor(maxPauseDuration)
 				}
+				publish()
+			case <-overdueChecks:
+				checkOverdue()
+			case request := <-requests:
+				request.Reply <- handleRequest(request)
+				publish()
 			}
 		}
 
+		checkTerminating()
 		prevTick = nextTick
-		results, err := sched.Tick(runner, nextTick)
+		first = false
+		launched := time.Now().In(location)
+		if stats != nil {
+			stats.ObserveTick(nextTick, launched)
+		}
+
+		scheduled := nextTick
+		bus.Publish(events.Event{Type: events.TickStarted, Time: time.Now().In(location),
+			Scheduled: &scheduled, Message: "Tick started"})
+
+		reloadPauseFile()
+		tickRunner := runner
+		if spread > 0 {
+			// only the scheduled tasks of this shard are spread, and only
+			// here, so that dumping and planning are not slowed
+			due := schedule.Due(owned, nextTick)
+			schedule.SortByPriority(due, table)
+			tickRunner = schedule.NewSpreadTaskRunner(tickRunner, due, spread)
+		}
+
+		if stopOnCapacity {
+			tickRunner = ecstaskrunner.NewCapacityGuardTaskRunner(runner, table)
+		}
+
+		results, err := sched.Tick(pauses.Wrap(tickRunner, nextTick), nextTick)
 		if err != nil {
-			log.Fatalf("Fatal error in tick: %s", err)
-		}
-
-		for task, result := range results {
-			if verbosity >= DEBUG_DETAIL {
-				switch info := result.Info.(type) {
-				default:
-				case *retry.RetryInfo:
-					if info.MaxRetries > 0 {
-						log.Printf("Retrying %s (attempt %d of %d)\n",
-							task, info.Attempt, info.MaxRetries)
-					} else {
-						log.Printf("Retrying %s (attempt %d)\n", task, info.Attempt)
-					}
-				}
+			logging.Fatal(logger, "tick_error", "Fatal error in tick",
+				logging.KeyScheduled, nextTick, logging.KeyError, err)
+		}
+
+		if stats != nil {
+			stats.ObserveResults(results)
+		}
+
+		publishResults(results, nextTick, launched)
+		logResults(logger, results, nextTick, cluster)
+		checkOverdue()
+		saveState()
+	}
+}
+
+// errorClass gives a short, stable, classification of an error, eg: the AWS
+// error code
+func errorClass(err error) string {
+	switch e := err.(type) {
+	case awserr.Error:
+		return e.Code()
+	case *maintenance.SuppressedError:
+		return "maintenance"
+	case *pause.PausedError:
+		return "paused"
+	case *ecstaskrunner.CapacitySkippedError:
+		return "capacity"
+	case *mutex.BusyError:
+		return "mutex"
+	case *dependency.UpstreamFailedError:
+		return "dependency"
+	}
+
+	return "unknown"
+}
+
+// logResults logs the outcome of each task run. "scheduled" is the tick in
+// which the tasks ran, or zero if they were run on demand.
+func logResults(logger *slog.Logger, results map[string]*taskrunner.TaskStatus, scheduled time.Time, cluster string) {
+	for task, result := range results {
+		fields := []interface{}{logging.KeyTask, task, logging.KeyCluster, cluster}
+		if !scheduled.IsZero() {
+			fields = append(fields, logging.KeyScheduled, scheduled)
+		}
+
+		switch info := result.Info.(type) {
+		default:
+		case *retry.RetryInfo:
+			fields = append(fields, logging.KeyAttempt, info.Attempt)
+			if info.MaxRetries > 0 {
+				fields = append(fields, logging.KeyMaxAttempts, info.MaxRetries)
 			}
 
-			if result.Ran {
-				if verbosity >= DEBUG_DETAIL {
-					switch output := result.Output.(type) {
-					default:
-						log.Printf("%s Scheduled to Run via an unknown method", task)
-					case *simulatedStatus:
-						log.Printf("[-simulate] %s Would have been scheduled to run as %s", task, output.TaskName)
-					case *ecs.RunTaskOutput:
-						for _, scheduledTask := range output.Tasks {
-							log.Printf("%s Scheduled to run on Container Instance %s using Task Definition %s\n",
-								task, *scheduledTask.ContainerInstanceArn, *scheduledTask.TaskDefinitionArn)
-						}
-					}
-				}
-			} else {
-				if result.Error != nil {
-					log.Printf("Error when running task '%s': %s", task, result.Error)
-				}
+			logging.Event(logger, logging.LevelDetail, "task_retry", "Retrying task", fields...)
+		case *mutex.DeferredInfo:
+			logging.Event(logger, logging.LevelDetail, "task_deferred", "Running task deferred while its mutex group was busy",
+				append(fields, "deferred_from", info.Scheduled)...)
+		case *dependency.TriggeredInfo:
+			logging.Event(logger, logging.LevelDetail, "task_triggered", "Running task after the task it depends upon completed",
+				append(fields, "after", info.After)...)
+		case *maintenance.CatchUpInfo:
+			logging.Event(logger, logging.LevelDetail, "task_catchup", "Catching-up task missed during maintenance window",
+				append(fields, logging.KeyWindow, info.Window.Name, logging.KeyMissed, info.Missed)...)
+		}
 
-				for _, warning := range result.Warnings {
-					log.Printf("Warning when running task '%s': %s", task, warning)
+		if result.Ran {
+			switch output := result.Output.(type) {
+			default:
+				logging.Event(logger, logging.LevelDetail, "task_launched", "Task scheduled to run via an unknown method",
+					fields...)
+			case *simulatedStatus:
+				logging.Event(logger, logging.LevelDetail, "task_launched", "[-simulate] Task would have been scheduled to run",
+					append(fields, "as", output.TaskName)...)
+			case *ecs.RunTaskOutput:
+				for _, scheduledTask := range output.Tasks {
+					logging.Event(logger, logging.LevelDetail, "task_launched", "Task scheduled to run",
+						append(fields,
+							logging.KeyTaskArn, aws.StringValue(scheduledTask.TaskArn),
+							logging.KeyContainerInstanceArn, aws.StringValue(scheduledTask.ContainerInstanceArn),
+							logging.KeyTaskDefinitionArn, aws.StringValue(scheduledTask.TaskDefinitionArn))...)
 				}
 			}
+
+			continue
+		}
+
+		if result.Error != nil {
+			logging.Event(logger, logging.LevelError, "task_error", "Error when running task",
+				append(fields, logging.KeyErrorClass, errorClass(result.Error), logging.KeyError, result.Error)...)
+		}
+
+		// each RunTask failure is reported as a warning, in order
+		var failures []*ecs.Failure
+		if output, ok := result.Output.(*ecs.RunTaskOutput); ok && output != nil {
+			failures = output.Failures
+		}
+
+		for i, warning := range result.Warnings {
+			class := errorClass(warning)
+			event := "task_warning"
+			switch {
+			case result.Running:
+				class = "running"
+				event = "task_skipped"
+			case result.Throttled:
+				event = "task_throttled"
+			case suppression.IsDeliberate(warning):
+				event = "task_skipped"
+			case i < len(failures):
+				class = aws.StringValue(failures[i].Reason)
+			}
+
+			logging.Event(logger, logging.LevelWarn, event, "Warning when running task",
+				append(fields, logging.KeyErrorClass, class, logging.KeyError, warning)...)
 		}
 	}
 }
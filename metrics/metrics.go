@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+// Metrics are the metrics collected about the scheduler itself
+type Metrics struct {
+	Registry *Registry
+
+	Ticks            *Counter
+	TickLateness     *Histogram
+	Launched         *Counter
+	SkippedAsRunning *Counter
+	Skipped          *Counter
+	Failed           *Counter
+	Throttled        *Counter
+	Retried          *Counter
+	APIDuration      *Histogram
+	APIErrors        *Counter
+	Paused           *Gauge
+
+	mu       sync.Mutex
+	nextTick time.Time
+	now      func() time.Time
+}
+
+func New() *Metrics {
+	registry := NewRegistry()
+	m := &Metrics{Registry: registry, now: time.Now}
+
+	m.Ticks = registry.NewCounter("ecscron_ticks_total",
+		"Number of ticks processed")
+	m.TickLateness = registry.NewHistogram("ecscron_tick_lateness_seconds",
+		"How late each tick was processed, relative to its scheduled time",
+		[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300})
+	m.Launched = registry.NewCounter("ecscron_tasks_launched_total",
+		"Number of tasks successfully launched", "task")
+	m.SkippedAsRunning = registry.NewCounter("ecscron_tasks_skipped_running_total",
+		"Number of task runs skipped because the task was still running", "task")
+	m.Skipped = registry.NewCounter("ecscron_tasks_skipped_total",
+		"Number of task runs deliberately skipped, eg: during a maintenance window or while paused", "task")
+	m.Failed = registry.NewCounter("ecscron_tasks_failed_total",
+		"Number of task runs which failed (with an error or warning)", "task")
+	m.Throttled = registry.NewCounter("ecscron_tasks_throttled_total",
+		"Number of task runs not made because the ECS API throttled the request", "task")
+	m.Retried = registry.NewCounter("ecscron_tasks_retried_total",
+		"Number of task runs which were retries of an earlier failure", "task")
+	m.APIDuration = registry.NewHistogram("ecscron_ecs_api_duration_seconds",
+		"Latency of ECS API calls",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "operation")
+	m.APIErrors = registry.NewCounter("ecscron_ecs_api_errors_total",
+		"Number of ECS API calls which returned an error", "operation")
+	m.Paused = registry.NewGauge("ecscron_paused",
+		"1 if the scheduler is paused, otherwise 0")
+	registry.NewGaugeFunc("ecscron_next_tick_seconds",
+		"Seconds until the next tick (negative if overdue)", m.secondsUntilNextTick)
+
+	return m
+}
+
+func (m *Metrics) SetNextTick(next time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextTick = next
+}
+
+func (m *Metrics) secondsUntilNextTick() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nextTick.IsZero() {
+		return 0
+	}
+
+	return m.nextTick.Sub(m.now()).Seconds()
+}
+
+func (m *Metrics) SetPaused(paused bool) {
+	if paused {
+		m.Paused.Set(1)
+	} else {
+		m.Paused.Set(0)
+	}
+}
+
+// ObserveTick records a tick scheduled for "at" which began processing at
+// "started"
+func (m *Metrics) ObserveTick(at time.Time, started time.Time) {
+	m.Ticks.Inc()
+
+	lateness := started.Sub(at).Seconds()
+	if lateness < 0 {
+		lateness = 0
+	}
+
+	m.TickLateness.Observe(lateness)
+}
+
+// ObserveResults records the outcome of each task run within a tick
+func (m *Metrics) ObserveResults(results map[string]*taskrunner.TaskStatus) {
+	for task, result := range results {
+		if _, ok := result.Info.(*retry.RetryInfo); ok {
+			m.Retried.Inc(task)
+		}
+
+		switch {
+		case result.Ran:
+			m.Launched.Inc(task)
+		case result.Running:
+			m.SkippedAsRunning.Inc(task)
+		case result.Throttled:
+			m.Throttled.Inc(task)
+		case deliberate(result):
+			m.Skipped.Inc(task)
+		default:
+			m.Failed.Inc(task)
+		}
+	}
+}
+
+// deliberate returns true if a task was deliberately not run (eg: paused),
+// which should not be counted as a failure
+func deliberate(result *taskrunner.TaskStatus) bool {
+	for _, warning := range result.Warnings {
+		if suppression.IsDeliberate(warning) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObserveAPI records the latency and outcome of a single ECS API call, and is
+// suitable for use with ecstaskrunner.NewObservedService
+func (m *Metrics) ObserveAPI(operation string, duration time.Duration, err error) {
+	m.APIDuration.Observe(duration.Seconds(), operation)
+	if err != nil {
+		m.APIErrors.Inc(operation)
+	}
+}
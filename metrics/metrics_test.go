@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule/retry"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+// deliberateError is a suppression.Deliberate reason, eg: a maintenance window
+type deliberateError struct{}
+
+func (e *deliberateError) Error() string { return "intentional" }
+func (e *deliberateError) Deliberate()   {}
+
+func TestMetrics(t *testing.T) {
+	t.Run("ObserveResults should count each outcome per task", func(t *testing.T) {
+		m := New()
+		m.ObserveResults(map[string]*taskrunner.TaskStatus{
+			"launched": &taskrunner.TaskStatus{Ran: true},
+			"running":  &taskrunner.TaskStatus{Running: true},
+			"failed":   &taskrunner.TaskStatus{Warnings: []error{errors.New("intentional")}},
+			"skipped":  &taskrunner.TaskStatus{Warnings: []error{&deliberateError{}}},
+			"retried":  &taskrunner.TaskStatus{Ran: true, Info: &retry.RetryInfo{Attempt: 2}},
+		})
+
+		buf := new(bytes.Buffer)
+		_, _ = m.Registry.WriteTo(buf)
+
+		for _, line := range []string{
+			"ecscron_tasks_launched_total{task=\"launched\"} 1",
+			"ecscron_tasks_launched_total{task=\"retried\"} 1",
+			"ecscron_tasks_skipped_running_total{task=\"running\"} 1",
+			"ecscron_tasks_failed_total{task=\"failed\"} 1",
+			"ecscron_tasks_skipped_total{task=\"skipped\"} 1",
+			"ecscron_tasks_retried_total{task=\"retried\"} 1",
+		} {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Fatalf("Metrics did not include '%s':\n%s", line, buf.String())
+			}
+		}
+
+		if strings.Contains(buf.String(), "ecscron_tasks_failed_total{task=\"skipped\"}") {
+			t.Fatalf("A deliberate skip was counted as a failure:\n%s", buf.String())
+		}
+	})
+
+	t.Run("ObserveTick should count ticks and lateness", func(t *testing.T) {
+		m := New()
+		at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		m.ObserveTick(at, at.Add(2*time.Second))
+
+		buf := new(bytes.Buffer)
+		_, _ = m.Registry.WriteTo(buf)
+
+		if !strings.Contains(buf.String(), "\necscron_ticks_total 1\n") ||
+			!strings.Contains(buf.String(), "\necscron_tick_lateness_seconds_sum 2\n") {
+			t.Fatalf("Metrics did not include the tick:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Next tick should be reported relative to now", func(t *testing.T) {
+		m := New()
+		now := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		m.now = func() time.Time { return now }
+		m.SetNextTick(now.Add(30 * time.Second))
+
+		buf := new(bytes.Buffer)
+		_, _ = m.Registry.WriteTo(buf)
+
+		if !strings.Contains(buf.String(), "\necscron_next_tick_seconds 30\n") {
+			t.Fatalf("Metrics did not include the time until the next tick:\n%s", buf.String())
+		}
+	})
+
+	t.Run("ObserveAPI should record latency and errors", func(t *testing.T) {
+		m := New()
+		m.ObserveAPI("RunTask", time.Second, nil)
+		m.ObserveAPI("RunTask", time.Second, errors.New("intentional"))
+
+		buf := new(bytes.Buffer)
+		_, _ = m.Registry.WriteTo(buf)
+
+		if !strings.Contains(buf.String(), "ecscron_ecs_api_duration_seconds_count{operation=\"RunTask\"} 2\n") ||
+			!strings.Contains(buf.String(), "ecscron_ecs_api_errors_total{operation=\"RunTask\"} 1\n") {
+			t.Fatalf("Metrics did not include the API calls:\n%s", buf.String())
+		}
+	})
+}
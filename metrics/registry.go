@@ -0,0 +1,307 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Registry holds a set of metrics, and writes them in the Prometheus text
+// exposition format. Only what ecscron needs is supported: counters, gauges
+// and histograms, each with an optional fixed set of label names.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	buf := new(bytes.Buffer)
+	for _, m := range metrics {
+		if err := m.write(buf); err != nil {
+			return 0, err
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = r.WriteTo(w)
+}
+
+// series holds one value per distinct combination of label values
+type series struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string][]string
+}
+
+func newSeries(name string, help string, kind string, labels []string) series {
+	return series{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string][]string),
+	}
+}
+
+// key returns a unique (and sortable) key for a combination of label values
+func (s *series) key(labelValues []string) string {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d",
+			s.name, len(s.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\x00")
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string{}, labelValues...)
+	}
+
+	return key
+}
+
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *series) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, s.help, s.name, s.kind)
+	return err
+}
+
+// formatLabels formats label pairs as {name="value",...}, with any extra
+// pairs (eg: histogram "le") appended
+func formatLabels(names []string, values []string, extra ...string) string {
+	pairs := []string{}
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i])))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[i], escapeLabel(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	return strings.Replace(value, "\n", "\\n", -1)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type Counter struct {
+	series
+	counts map[string]float64
+}
+
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{series: newSeries(name, help, "counter", labels), counts: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[c.key(labelValues)] += value
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+
+	// an unlabelled counter is always reported, even if never incremented
+	if len(c.labels) == 0 && len(c.counts) == 0 {
+		_, err := fmt.Fprintf(w, "%s 0\n", c.name)
+		return err
+	}
+
+	for _, key := range c.sortedKeys() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name,
+			formatLabels(c.labels, c.values[key]), formatValue(c.counts[key])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Gauge struct {
+	series
+	value    float64
+	function func() float64
+}
+
+func (r *Registry) NewGauge(name string, help string) *Gauge {
+	g := &Gauge{series: newSeries(name, help, "gauge", nil)}
+	r.register(g)
+	return g
+}
+
+// NewGaugeFunc creates a Gauge whose value is determined by calling the given
+// function each time the metrics are written
+func (r *Registry) NewGaugeFunc(name string, help string, function func() float64) *Gauge {
+	g := &Gauge{series: newSeries(name, help, "gauge", nil), function: function}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = value
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	value := g.value
+	function := g.function
+	g.mu.Unlock()
+
+	if function != nil {
+		value = function()
+	}
+
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%s %s\n", g.name, formatValue(value))
+	return err
+}
+
+type histogramValues struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+type Histogram struct {
+	series
+	bounds     []float64
+	histograms map[string]*histogramValues
+}
+
+func (r *Registry) NewHistogram(name string, help string, bounds []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		series:     newSeries(name, help, "histogram", labels),
+		bounds:     bounds,
+		histograms: make(map[string]*histogramValues),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(labelValues)
+	values, ok := h.histograms[key]
+	if !ok {
+		values = &histogramValues{buckets: make([]uint64, len(h.bounds))}
+		h.histograms[key] = values
+	}
+
+	for i, bound := range h.bounds {
+		if value <= bound {
+			values.buckets[i] += 1
+		}
+	}
+
+	values.count += 1
+	values.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+
+	for _, key := range h.sortedKeys() {
+		labelValues := h.values[key]
+		values := h.histograms[key]
+
+		for i, bound := range h.bounds {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				formatLabels(h.labels, labelValues, "le", formatValue(bound)),
+				values.buckets[i]); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, formatLabels(h.labels, labelValues, "le", "+Inf"), values.count,
+			h.name, formatLabels(h.labels, labelValues), formatValue(values.sum),
+			h.name, formatLabels(h.labels, labelValues), values.count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("Counters should be written with sorted labels", func(t *testing.T) {
+		registry := NewRegistry()
+		counter := registry.NewCounter("test_total", "A test counter", "task")
+		counter.Inc("b")
+		counter.Inc("a\"quoted\"")
+		counter.Add(2, "b")
+
+		buf := new(bytes.Buffer)
+		if _, err := registry.WriteTo(buf); err != nil {
+			t.Fatalf("Unexpected error while writing metrics: %s", err)
+		}
+
+		expected := "# HELP test_total A test counter\n" +
+			"# TYPE test_total counter\n" +
+			"test_total{task=\"a\\\"quoted\\\"\"} 1\n" +
+			"test_total{task=\"b\"} 3\n"
+		if buf.String() != expected {
+			t.Fatalf("Counter was not written as expected:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Unlabelled counters should be written even when zero", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.NewCounter("test_total", "A test counter")
+
+		buf := new(bytes.Buffer)
+		_, _ = registry.WriteTo(buf)
+		if !strings.Contains(buf.String(), "\ntest_total 0\n") {
+			t.Fatalf("Unlabelled counter was not written:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Gauges should be written", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.NewGauge("test_gauge", "A test gauge").Set(1.5)
+		registry.NewGaugeFunc("test_func", "A test gauge func", func() float64 { return -2 })
+
+		buf := new(bytes.Buffer)
+		_, _ = registry.WriteTo(buf)
+		if !strings.Contains(buf.String(), "\ntest_gauge 1.5\n") ||
+			!strings.Contains(buf.String(), "\ntest_func -2\n") {
+			t.Fatalf("Gauges were not written as expected:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Histograms should be written with cumulative buckets", func(t *testing.T) {
+		registry := NewRegistry()
+		histogram := registry.NewHistogram("test_seconds", "A test histogram", []float64{1, 5}, "op")
+		histogram.Observe(0.5, "x")
+		histogram.Observe(2, "x")
+		histogram.Observe(10, "x")
+
+		buf := new(bytes.Buffer)
+		_, _ = registry.WriteTo(buf)
+
+		expected := "# HELP test_seconds A test histogram\n" +
+			"# TYPE test_seconds histogram\n" +
+			"test_seconds_bucket{op=\"x\",le=\"1\"} 1\n" +
+			"test_seconds_bucket{op=\"x\",le=\"5\"} 2\n" +
+			"test_seconds_bucket{op=\"x\",le=\"+Inf\"} 3\n" +
+			"test_seconds_sum{op=\"x\"} 12.5\n" +
+			"test_seconds_count{op=\"x\"} 3\n"
+		if buf.String() != expected {
+			t.Fatalf("Histogram was not written as expected:\n%s", buf.String())
+		}
+	})
+
+	t.Run("ServeHTTP should write the metrics", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.NewGauge("test_gauge", "A test gauge")
+
+		recorder := httptest.NewRecorder()
+		registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		if !strings.Contains(recorder.Body.String(), "test_gauge 0") {
+			t.Fatalf("ServeHTTP did not write the metrics:\n%s", recorder.Body.String())
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"time"
+)
+
+type calendarDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// A Calendar is a set of whole dates, such as public holidays or a change
+// freeze. Dates are compared in the location of the time being checked.
+type Calendar struct {
+	dates map[calendarDate]bool
+}
+
+func NewCalendar() *Calendar {
+	return &Calendar{dates: make(map[calendarDate]bool)}
+}
+
+func (c *Calendar) Add(year int, month time.Month, day int) {
+	// normalize (eg: Jan 32 -> Feb 1)
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	c.dates[calendarDate{t.Year(), t.Month(), t.Day()}] = true
+}
+
+// AddRange adds every date from "from" through "until", inclusive
+func (c *Calendar) AddRange(from time.Time, until time.Time) {
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, time.UTC)
+
+	for !day.After(last) {
+		c.Add(day.Year(), day.Month(), day.Day())
+		day = day.AddDate(0, 0, 1)
+	}
+}
+
+// AddCalendar adds every date within another Calendar
+func (c *Calendar) AddCalendar(other *Calendar) {
+	for date := range other.dates {
+		c.dates[date] = true
+	}
+}
+
+func (c *Calendar) Contains(t time.Time) bool {
+	return c.dates[calendarDate{t.Year(), t.Month(), t.Day()}]
+}
+
+func (c *Calendar) Len() int {
+	return len(c.dates)
+}
+
+type BlackoutPolicy int
+
+const (
+	// Occurrences on blackout dates do not happen at all
+	BlackoutSkip BlackoutPolicy = iota
+
+	// Occurrences on blackout dates happen at the same time-of-day on the
+	// next business day (a weekday which is not itself a blackout date)
+	BlackoutNextBusinessDay
+)
+
+// The maximum number of consecutive days / occurrences considered when
+// searching past blackout dates, so that a calendar which blacks-out
+// everything cannot stall the scheduler.
+const maxBlackoutSearch = 1000
+
+// A BlackoutNexter removes (or moves) any events of another Nexter which fall
+// on a date in the given Calendar.
+type BlackoutNexter struct {
+	Nexter   Nexter
+	Calendar *Calendar
+	Policy   BlackoutPolicy
+}
+
+func NewBlackoutNexter(nexter Nexter, calendar *Calendar, policy BlackoutPolicy) *BlackoutNexter {
+	return &BlackoutNexter{Nexter: nexter, Calendar: calendar, Policy: policy}
+}
+
+func (b *BlackoutNexter) Next(after time.Time) time.Time {
+	if b.Policy == BlackoutNextBusinessDay {
+		return b.nextShifted(after)
+	}
+
+	cursor := after
+	for i := 0; i < maxBlackoutSearch; i++ {
+		next := b.Nexter.Next(cursor)
+		if next.IsZero() || !b.Calendar.Contains(next) {
+			return next
+		}
+
+		// nothing else on this date can run, either
+		cursor = endOfDay(next)
+	}
+
+	return time.Time{}
+}
+
+func (b *BlackoutNexter) isBusinessDay(t time.Time) bool {
+	weekday := t.Weekday()
+	return weekday != time.Saturday && weekday != time.Sunday && !b.Calendar.Contains(t)
+}
+
+// shift returns the same time-of-day as "t", on the next business day
+func (b *BlackoutNexter) shift(t time.Time) time.Time {
+	for i := 1; i <= maxBlackoutSearch; i++ {
+		shifted := time.Date(t.Year(), t.Month(), t.Day()+i,
+			t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+
+		if b.isBusinessDay(shifted) {
+			return shifted
+		}
+	}
+
+	return time.Time{}
+}
+
+func (b *BlackoutNexter) nextShifted(after time.Time) time.Time {
+	var earliest time.Time
+
+	// Events on blackout dates prior to "after" may have been moved to a time
+	// later than "after", so start from the beginning of whatever run of
+	// non-business days leads up to it.
+	start := startOfDay(after)
+	for i := 0; i < maxBlackoutSearch; i++ {
+		previous := start.AddDate(0, 0, -1)
+		if b.isBusinessDay(previous) {
+			break
+		}
+
+		start = previous
+	}
+
+	cursor := start.Add(time.Duration(-1))
+	for i := 0; i < maxBlackoutSearch; i++ {
+		next := b.Nexter.Next(cursor)
+
+		// events are only ever moved later, so nothing further can be earlier
+		if next.IsZero() || (!earliest.IsZero() && !next.Before(earliest)) {
+			break
+		}
+
+		if !b.Calendar.Contains(next) {
+			if next.After(after) {
+				earliest = next
+				break
+			}
+
+			// only events on blackout dates are of interest prior to "after"
+			cursor = endOfDay(next)
+			if cursor.After(after) {
+				cursor = after
+			}
+			continue
+		}
+
+		shifted := b.shift(next)
+		if shifted.IsZero() {
+			cursor = endOfDay(next)
+			continue
+		}
+
+		if shifted.After(after) {
+			if earliest.IsZero() || shifted.Before(earliest) {
+				earliest = shifted
+			}
+
+			// later events on the same date can only be moved to later times
+			cursor = endOfDay(next)
+			continue
+		}
+
+		// The event was moved, but not far enough. Later events on the same
+		// date can only move past "after" when moved onto the same date as it.
+		cursor = endOfDay(next)
+		if startOfDay(shifted).Equal(startOfDay(after)) {
+			sameTime := time.Date(next.Year(), next.Month(), next.Day(),
+				after.Hour(), after.Minute(), after.Second(), after.Nanosecond(), next.Location())
+
+			if sameTime.After(next) {
+				cursor = sameTime
+			} else {
+				cursor = next
+			}
+		}
+	}
+
+	return earliest
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func endOfDay(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, 1).Add(time.Duration(-1))
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// returns a Nexter which fires at 09:00 UTC on the given weekdays
+func nineAmOn(weekdays ...time.Weekday) Nexter {
+	return NextFunc(func(after time.Time) time.Time {
+		day := time.Date(after.Year(), after.Month(), after.Day(), 9, 0, 0, 0, time.UTC)
+		for i := 0; i < 14; i++ {
+			candidate := day.AddDate(0, 0, i)
+			if !candidate.After(after) {
+				continue
+			}
+
+			for _, weekday := range weekdays {
+				if candidate.Weekday() == weekday {
+					return candidate
+				}
+			}
+		}
+
+		return time.Time{}
+	})
+}
+
+func TestCalendar(t *testing.T) {
+	t.Run("Contains should match added dates only", func(t *testing.T) {
+		calendar := NewCalendar()
+		calendar.Add(2006, time.January, 2)
+
+		if !calendar.Contains(time.Date(2006, 1, 2, 23, 59, 0, 0, time.UTC)) {
+			t.Fatalf("Calendar did not contain an added date")
+		}
+
+		if calendar.Contains(time.Date(2006, 1, 3, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Calendar contained a date which was not added")
+		}
+	})
+
+	t.Run("AddRange should be inclusive", func(t *testing.T) {
+		calendar := NewCalendar()
+		calendar.AddRange(time.Date(2006, 1, 30, 0, 0, 0, 0, time.UTC),
+			time.Date(2006, 2, 1, 0, 0, 0, 0, time.UTC))
+
+		if calendar.Len() != 3 {
+			t.Fatalf("AddRange did not add exactly 3 dates: %d", calendar.Len())
+		}
+
+		if !calendar.Contains(time.Date(2006, 2, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Fatalf("AddRange did not include the final date")
+		}
+	})
+}
+
+func TestBlackoutNexter(t *testing.T) {
+	// Friday 2006-01-06 is a holiday
+	calendar := NewCalendar()
+	calendar.Add(2006, time.January, 6)
+
+	t.Run("Skip should not return events on blackout dates", func(t *testing.T) {
+		nexter := NewBlackoutNexter(nineAmOn(time.Thursday, time.Friday, time.Monday),
+			calendar, BlackoutSkip)
+
+		result := nexter.Next(time.Date(2006, 1, 5, 9, 0, 0, 0, time.UTC))
+		if !result.Equal(time.Date(2006, 1, 9, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Next did not skip the blackout date: %v", result)
+		}
+	})
+
+	t.Run("NextBusinessDay should move events past the weekend", func(t *testing.T) {
+		nexter := NewBlackoutNexter(nineAmOn(time.Friday), calendar, BlackoutNextBusinessDay)
+
+		expected := time.Date(2006, 1, 9, 9, 0, 0, 0, time.UTC)
+		for _, after := range []time.Time{
+			time.Date(2006, 1, 5, 12, 0, 0, 0, time.UTC),
+			time.Date(2006, 1, 6, 9, 0, 0, 0, time.UTC),
+			time.Date(2006, 1, 7, 12, 0, 0, 0, time.UTC),
+			expected.Add(time.Duration(-1)),
+		} {
+			result := nexter.Next(after)
+			if !result.Equal(expected) {
+				t.Fatalf("Next(%v) did not move the event to the next business day: %v",
+					after, result)
+			}
+		}
+
+		result := nexter.Next(expected)
+		if !result.Equal(time.Date(2006, 1, 13, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Next did not continue after the moved event: %v", result)
+		}
+	})
+
+	t.Run("NextBusinessDay should not move weekend events", func(t *testing.T) {
+		nexter := NewBlackoutNexter(nineAmOn(time.Friday, time.Saturday), calendar,
+			BlackoutNextBusinessDay)
+
+		result := nexter.Next(time.Date(2006, 1, 5, 12, 0, 0, 0, time.UTC))
+		if !result.Equal(time.Date(2006, 1, 7, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Next did not return the (non-blackout) weekend event: %v", result)
+		}
+
+		result = nexter.Next(time.Date(2006, 1, 7, 9, 0, 0, 0, time.UTC))
+		if !result.Equal(time.Date(2006, 1, 9, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Next did not return the moved event after the weekend event: %v", result)
+		}
+	})
+
+	t.Run("Everything blacked-out should return zero", func(t *testing.T) {
+		everything := NewCalendar()
+		everything.AddRange(time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		nexter := NewBlackoutNexter(nineAmOn(time.Monday, time.Tuesday, time.Wednesday,
+			time.Thursday, time.Friday, time.Saturday, time.Sunday), everything, BlackoutSkip)
+		result := nexter.Next(time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC))
+		if !result.IsZero() {
+			t.Fatalf("Next returned an event from an entirely blacked-out calendar: %v", result)
+		}
+	})
+}
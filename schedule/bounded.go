@@ -0,0 +1,42 @@
+package schedule
+
+import (
+	"time"
+)
+
+// A BoundedNexter limits another Nexter to an active window: events before
+// "From" are skipped, and no events are returned at or after "Until".
+// Either bound may be left as a zero-value to leave that side of the window
+// open.
+type BoundedNexter struct {
+	Nexter Nexter
+	From   time.Time
+	Until  time.Time
+}
+
+func NewBoundedNexter(nexter Nexter, from time.Time, until time.Time) *BoundedNexter {
+	return &BoundedNexter{Nexter: nexter, From: from, Until: until}
+}
+
+func (b *BoundedNexter) Next(after time.Time) time.Time {
+	if !b.From.IsZero() && after.Before(b.From) {
+		after = b.From.Add(time.Duration(-1))
+	}
+
+	next := b.Nexter.Next(after)
+	if next.IsZero() {
+		return next
+	}
+
+	if !b.Until.IsZero() && !next.Before(b.Until) {
+		return time.Time{}
+	}
+
+	return next
+}
+
+// Expired reports whether the window has closed as of the given time, meaning
+// no further events can ever be returned.
+func (b *BoundedNexter) Expired(at time.Time) bool {
+	return !b.Until.IsZero() && !at.Before(b.Until)
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoundedNexter(t *testing.T) {
+	everyMinute := NextFunc(func(after time.Time) time.Time {
+		return after.Truncate(time.Minute).Add(time.Minute)
+	})
+
+	t.Run("Next should skip events before From", func(t *testing.T) {
+		from := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		bounded := NewBoundedNexter(everyMinute, from, time.Time{})
+
+		result := bounded.Next(from.Add(-time.Hour))
+		if !result.Equal(from) {
+			t.Fatalf("Next did not return the first event at the From bound: %v", result)
+		}
+
+		result = bounded.Next(from)
+		if !result.Equal(from.Add(time.Minute)) {
+			t.Fatalf("Next did not pass-through once inside the window: %v", result)
+		}
+	})
+
+	t.Run("Next should return zero at or after Until", func(t *testing.T) {
+		until := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		bounded := NewBoundedNexter(everyMinute, time.Time{}, until)
+
+		result := bounded.Next(until.Add(-2 * time.Minute))
+		if !result.Equal(until.Add(-time.Minute)) {
+			t.Fatalf("Next did not pass-through before the Until bound: %v", result)
+		}
+
+		result = bounded.Next(until.Add(-time.Minute))
+		if !result.IsZero() {
+			t.Fatalf("Next returned an event at the (exclusive) Until bound: %v", result)
+		}
+	})
+
+	t.Run("Expired should be true only once Until has passed", func(t *testing.T) {
+		until := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		bounded := NewBoundedNexter(everyMinute, time.Time{}, until)
+
+		if bounded.Expired(until.Add(time.Duration(-1))) {
+			t.Fatalf("Window reported as expired before Until")
+		}
+
+		if !bounded.Expired(until) {
+			t.Fatalf("Window not reported as expired at Until")
+		}
+
+		if NewBoundedNexter(everyMinute, time.Time{}, time.Time{}).Expired(until) {
+			t.Fatalf("Open-ended window reported as expired")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+)
+
+var ignoredMatcher *regexp.Regexp
+var dateListMatcher *regexp.Regexp
+
+func init() {
+	ignoredMatcher = regexp.MustCompile("^\\s*(?:#.*)?$")
+	dateListMatcher = regexp.MustCompile("^\\s*" +
+		"(\\d{4}-\\d{2}-\\d{2})" + // Date
+		"(?:\\s+(\\d{4}-\\d{2}-\\d{2}))?" + // (optional) last date of range
+		"(?:\\s+#.*)?" +
+		"\\s*$")
+}
+
+// Load reads a Calendar in either iCalendar (.ics) or "date list" format,
+// detected by the content.
+func Load(r io.Reader) (*schedule.Calendar, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Contains(content, []byte("BEGIN:VCALENDAR")) {
+		return LoadICal(bytes.NewReader(content))
+	}
+
+	return LoadDateList(bytes.NewReader(content))
+}
+
+// LoadDateList reads a Calendar consisting of one date per line, in
+// YYYY-MM-DD format. A line may instead contain two dates separated by
+// whitespace, to include every date from the first through the second.
+// Anything after a "#" is ignored.
+func LoadDateList(r io.Reader) (*schedule.Calendar, error) {
+	calendar := schedule.NewCalendar()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ignoredMatcher.MatchString(line) {
+			continue
+		}
+
+		matches := dateListMatcher.FindStringSubmatch(line)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("Unknown date list line format '%s'", line)
+		}
+
+		from, err := time.Parse("2006-01-02", matches[1])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse date '%s': %s", line, err)
+		}
+
+		until := from
+		if matches[2] != "" {
+			until, err = time.Parse("2006-01-02", matches[2])
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse date '%s': %s", line, err)
+			}
+
+			if until.Before(from) {
+				return nil, fmt.Errorf("Date range ends before it starts '%s'", line)
+			}
+		}
+
+		calendar.AddRange(from, until)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+// LoadICal reads a Calendar from the VEVENTs of an iCalendar (RFC 5545)
+// stream. Every date touched by an event is included. Recurring events are
+// not supported, and result in an error rather than being silently ignored.
+func LoadICal(r io.Reader) (*schedule.Calendar, error) {
+	calendar := schedule.NewCalendar()
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var inEvent bool
+	var start, end string
+	for _, line := range lines {
+		name, value := splitProperty(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end = "", ""
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if err := addEvent(calendar, start, end); err != nil {
+				return nil, err
+			}
+		case !inEvent:
+		case name == "DTSTART":
+			start = value
+		case name == "DTEND":
+			end = value
+		case name == "RRULE" || name == "RDATE":
+			return nil, fmt.Errorf("Recurring events are not supported: %s", line)
+		}
+	}
+
+	return calendar, nil
+}
+
+// unfold joins RFC 5545 "folded" content lines, which are continued on the
+// next line following a single leading space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// splitProperty splits a content line into its (upper-cased) name, without
+// parameters, and its value. eg: "DTSTART;VALUE=DATE:20061225" gives
+// "DTSTART" and "20061225"
+func splitProperty(line string) (string, string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return strings.ToUpper(line), ""
+	}
+
+	name := line[:colon]
+	if semicolon := strings.Index(name, ";"); semicolon >= 0 {
+		name = name[:semicolon]
+	}
+
+	return strings.ToUpper(name), line[colon+1:]
+}
+
+func addEvent(calendar *schedule.Calendar, start string, end string) error {
+	if start == "" {
+		return fmt.Errorf("Event without DTSTART")
+	}
+
+	from, fromDateOnly, err := parseICalTime(start)
+	if err != nil {
+		return err
+	}
+
+	if end == "" {
+		calendar.AddRange(from, from)
+		return nil
+	}
+
+	until, untilDateOnly, err := parseICalTime(end)
+	if err != nil {
+		return err
+	}
+
+	// DTEND is exclusive: an end at midnight does not touch that date
+	if (untilDateOnly || until.Equal(time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, until.Location()))) &&
+		until.After(from) {
+		until = until.AddDate(0, 0, -1)
+	}
+
+	if until.Before(from) {
+		if fromDateOnly {
+			until = from
+		} else {
+			return fmt.Errorf("Event ends before it starts: %s - %s", start, end)
+		}
+	}
+
+	calendar.AddRange(from, until)
+	return nil
+}
+
+func parseICalTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t, true, nil
+	}
+
+	// UTC (Z-suffixed) and "floating" times are both taken at face value, as
+	// only the date is of interest.
+	if t, err := time.Parse("20060102T150405", strings.TrimSuffix(value, "Z")); err == nil {
+		return t, false, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("Failed to parse iCalendar date '%s'", value)
+}
@@ -0,0 +1,99 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDateList(t *testing.T) {
+	t.Run("Dates and ranges should be loaded", func(t *testing.T) {
+		calendar, err := Load(strings.NewReader(
+			"# holidays\n" +
+				"2006-12-25\n" +
+				"2006-12-31 2007-01-01 # new year\n"))
+		if err != nil {
+			t.Fatalf("Loading a valid date list failed: %s", err)
+		}
+
+		for _, date := range []time.Time{
+			time.Date(2006, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2006, 12, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2007, 1, 1, 0, 0, 0, 0, time.UTC),
+		} {
+			if !calendar.Contains(date) {
+				t.Fatalf("Loaded calendar did not contain %v", date)
+			}
+		}
+
+		if calendar.Len() != 3 {
+			t.Fatalf("Loaded calendar did not contain exactly 3 dates: %d", calendar.Len())
+		}
+	})
+
+	t.Run("Invalid lines should fail", func(t *testing.T) {
+		for _, content := range []string{
+			"christmas\n",
+			"2006-13-01\n",
+			"2007-01-02 2007-01-01\n",
+		} {
+			if _, err := Load(strings.NewReader(content)); err == nil {
+				t.Fatalf("Loading an invalid date list succeeded: %s", content)
+			}
+		}
+	})
+}
+
+func TestLoadICal(t *testing.T) {
+	t.Run("All-day and timed events should be loaded", func(t *testing.T) {
+		calendar, err := Load(strings.NewReader(strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VEVENT",
+			"SUMMARY:Christmas",
+			"DTSTART;VALUE=DATE:20061225",
+			"DTEND;VALUE=DATE:20061227",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"SUMMARY:Change",
+			"  freeze",
+			"DTSTART:20070102T180000Z",
+			"DTEND:20070103T020000Z",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n")))
+		if err != nil {
+			t.Fatalf("Loading a valid iCalendar failed: %s", err)
+		}
+
+		for _, date := range []time.Time{
+			time.Date(2006, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2006, 12, 26, 0, 0, 0, 0, time.UTC),
+			time.Date(2007, 1, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2007, 1, 3, 0, 0, 0, 0, time.UTC),
+		} {
+			if !calendar.Contains(date) {
+				t.Fatalf("Loaded calendar did not contain %v", date)
+			}
+		}
+
+		if calendar.Len() != 4 {
+			t.Fatalf("Loaded calendar did not contain exactly 4 dates: %d", calendar.Len())
+		}
+	})
+
+	t.Run("Recurring events should fail", func(t *testing.T) {
+		_, err := Load(strings.NewReader(strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"BEGIN:VEVENT",
+			"DTSTART;VALUE=DATE:20061225",
+			"RRULE:FREQ=YEARLY",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\n")))
+
+		if err == nil {
+			t.Fatalf("Loading a recurring event did not fail")
+		}
+	})
+}
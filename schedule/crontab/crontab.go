@@ -5,71 +5,159 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorhill/cronexpr"
 	"github.com/wpalmer/ecscron/schedule"
 )
 
 var cronExprMatcher *regexp.Regexp
+var dependencyMatcher *regexp.Regexp
+var groupMatcher *regexp.Regexp
+var memberMatcher *regexp.Regexp
+var parameterMatcher *regexp.Regexp
+var placeholderMatcher *regexp.Regexp
+var valueMatcher *regexp.Regexp
 var ignoredMatcher *regexp.Regexp
+var optionMatcher *regexp.Regexp
+
+// formats accepted for the from= and until= options, in order of preference
+var dateTimeFormats = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+const dateFormat = "2006-01-02"
 
 func init() {
 	ignoredMatcher = regexp.MustCompile("^\\s*(?:#.*)?$")
+	optionMatcher = regexp.MustCompile("^([-_A-Za-z0-9]+)=(.*)$")
 	cronExprMatcher = regexp.MustCompile("^\\s*" +
 		"(" +
 		"@\\S+" + // Predefined
 		"|" +
 		"[-0-9*/,]+\\s+" + // Seconds
-		"[-0-9*/,]+\\s+" + // Minutes
-		"[-0-9*/,]+\\s+" + // Hours
+		"[-0-9*/,H()]+\\s+" + // Minutes
+		"[-0-9*/,H()]+\\s+" + // Hours
 		"[-0-9*/,LW]+\\s+" + // Day of month
 		"[-0-9A-Za-z*/,]+\\s+" + // Month
 		"[-0-9A-Za-z*/,L#]+\\s+" + // Day of week
 		"[-0-9*/,]+" + // Year
 		"|" +
-		"[-0-9*/,]+\\s+" + // Minutes
-		"[-0-9*/,]+\\s+" + // Hours
+		"[-0-9*/,H()]+\\s+" + // Minutes
+		"[-0-9*/,H()]+\\s+" + // Hours
 		"[-0-9*/,LW]+\\s+" + // Day of month
 		"[-0-9A-Za-z*/,]+\\s+" + // Month
 		"[-0-9A-Za-z*/,L#]+\\s+" + // Day of week
 		"[-0-9*/,]+" + // Year
 		"|" +
-		"[-0-9*/,]+\\s+" + // Minutes
-		"[-0-9*/,]+\\s+" + // Hours
+		"[-0-9*/,H()]+\\s+" + // Minutes
+		"[-0-9*/,H()]+\\s+" + // Hours
 		"[-0-9*/,LW]+\\s+" + // Day of month
 		"[-0-9A-Za-z*/,]+\\s+" + // Month
 		"[-0-9A-Za-z*/,L#]+" + // Day of week
 		")" +
 		"\\s+" +
-		"(\\S+)" +
+		"(\\S+)" + // Task
+		"((?:\\s+[-_A-Za-z0-9]+=[^\\s#]*)*)" + // Options
+		"(?:\\s+#.*)?" +
+		"\\s*$")
+
+	// the start of a block of task names, which may be given in place of a
+	// task, eg: "[group nightly]"
+	groupMatcher = regexp.MustCompile("^\\s*\\[group\\s+([^\\s=#,\\[\\]]+)\\]\\s*(?:#.*)?$")
+	memberMatcher = regexp.MustCompile("^\\s*([^\\s=#,\\[\\]]+)\\s*(?:#.*)?$")
+
+	// the values of a parameter, eg: "tenant=a,b,c", and its use in a task
+	// name, eg: "report-{{tenant}}"
+	parameterMatcher = regexp.MustCompile("^\\s*([-_A-Za-z0-9]+)=([^\\s#]*)\\s*(?:#.*)?$")
+	placeholderMatcher = regexp.MustCompile("\\{\\{([-_A-Za-z0-9]+)\\}\\}")
+	valueMatcher = regexp.MustCompile("^[-_A-Za-z0-9]+$")
+
+	// an entry triggered by other tasks (with after=), rather than by time
+	dependencyMatcher = regexp.MustCompile("^\\s*" +
+		"([^\\s=#]+)" + // Task
+		"((?:\\s+[-_A-Za-z0-9]+=[^\\s#]*)*)" + // Options
 		"(?:\\s+#.*)?" +
 		"\\s*$")
 }
 
+type entry struct {
+	line       string
+	task       string
+	parameters map[string]string
+	options    map[string]string
+	tags       []string
+	priority   int
+	mutex      []string
+	defers     bool
+	after      []string
+	bounded    *schedule.BoundedNexter
+}
+
 type Crontab struct {
 	schedule.BasicSchedule
-	table map[string]*schedule.NextList
+	table     map[string]*schedule.NextList
+	entries   []*entry
+	location  *time.Location
+	calendars map[string]*schedule.Calendar
+	splay     time.Duration
+
+	// the members of each task group, and the group being defined, if any
+	groups map[string][]string
+	block  string
+
+	// the values of each parameter of templated entries
+	parameters map[string][]string
 }
 
 func NewCrontab() *Crontab {
 	return &Crontab{
-		*schedule.NewBasicSchedule(),
-		make(map[string]*schedule.NextList),
+		BasicSchedule: *schedule.NewBasicSchedule(),
+		table:         make(map[string]*schedule.NextList),
+		location:      time.UTC,
+		calendars:     make(map[string]*schedule.Calendar),
+		groups:        make(map[string][]string),
+		parameters:    make(map[string][]string),
 	}
 }
 
+// SetLocation sets the timezone in which dates given as entry options (such
+// as from= and until=) are evaluated. It must be called prior to Parse/Load.
+func (s *Crontab) SetLocation(location *time.Location) {
+	s.location = location
+}
+
+// SetCalendar makes a Calendar available to entries (by name) via the
+// calendar= option. It must be called prior to Parse/Load.
+func (s *Crontab) SetCalendar(name string, calendar *schedule.Calendar) {
+	s.calendars[name] = calendar
+}
+
+// SetSplay sets the default maximum random delay applied to every entry,
+// unless overridden with the jitter= option. It must be called prior to
+// Parse/Load.
+func (s *Crontab) SetSplay(splay time.Duration) {
+	s.splay = splay
+}
+
 func (s *Crontab) Add(task string, nexter schedule.Nexter) {
-	var list *schedule.NextList
-	var ok bool
+	s.list(task).Add(nexter)
+}
 
-	list, ok = s.table[task]
+// list returns the NextList of a task, adding the task if it is not yet known
+func (s *Crontab) list(task string) *schedule.NextList {
+	list, ok := s.table[task]
 	if !ok {
 		list = &schedule.NextList{}
 		s.table[task] = list
 		s.Set(task, list)
 	}
 
-	list.Add(nexter)
+	return list
 }
 
 func (s *Crontab) Clear(task string) {
@@ -77,24 +165,787 @@ func (s *Crontab) Clear(task string) {
 	if ok {
 		list.Clear()
 	}
+
+	entries := []*entry{}
+	for _, e := range s.entries {
+		if e.task != task {
+			entries = append(entries, e)
+		}
+	}
+	s.entries = entries
+	s.SetPriority(task, 0)
 }
 
 func (s *Crontab) Parse(line string) (bool, error) {
+	if matches := groupMatcher.FindStringSubmatch(line); len(matches) > 0 {
+		return s.parseGroup(matches[1])
+	}
+
+	// a group's members are listed one per line, until any other line
+	if s.block != "" {
+		if matches := memberMatcher.FindStringSubmatch(line); len(matches) > 0 {
+			return s.parseMember(matches[1])
+		}
+
+		s.block = ""
+	}
+
+	if matches := parameterMatcher.FindStringSubmatch(line); len(matches) > 0 {
+		values := []string{}
+		if matches[2] != "" {
+			values = strings.Split(matches[2], ",")
+		}
+
+		if err := s.SetParameter(matches[1], values); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
 	matches := cronExprMatcher.FindStringSubmatch(line)
 
 	if len(matches) == 0 {
+		if matches := dependencyMatcher.FindStringSubmatch(line); len(matches) > 0 {
+			return s.parseDependency(line, matches[1], matches[2])
+		}
+
+		// only the name position of a time entry may contain "=", as
+		// otherwise the name could not be told apart from the options
+		if fields := strings.Fields(line); len(fields) > 1 && strings.Contains(fields[0], "=") && strings.Contains(line, "after=") {
+			return false, fmt.Errorf("Task '%s' in an after= entry cannot contain '='", fields[0])
+		}
+
 		return false, fmt.Errorf("Unknown crontab line format")
 	}
 
-	expr, err := cronexpr.Parse(matches[1])
-	if expr == nil {
+	// H is resolved for each member (below); this only checks the expression
+	resolved, err := ResolveHashed(matches[1], matches[2])
+	if err != nil {
+		return false, err
+	}
+
+	if expr, err := cronexpr.Parse(resolved); expr == nil {
 		return false, fmt.Errorf("Failed to parse cron expression: %s", err)
 	}
 
-	s.Add(matches[2], expr)
+	options, err := parseOptions(matches[3])
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := options["after"]; ok {
+		return false, fmt.Errorf("after= cannot be combined with a time expression")
+	}
+
+	instances, err := s.instances(matches[2], "")
+	if err != nil {
+		return false, err
+	}
+
+	e := &entry{line: strings.TrimSpace(line), options: options}
+	if err := e.parseCommon(options); err != nil {
+		return false, err
+	}
+
+	var calendar *schedule.Calendar
+	policy := schedule.BlackoutSkip
+	if names, ok := options["calendar"]; ok {
+		calendar = schedule.NewCalendar()
+		for _, name := range strings.Split(names, ",") {
+			named, ok := s.calendars[name]
+			if !ok {
+				return false, fmt.Errorf("Unknown calendar '%s'", name)
+			}
+
+			calendar.AddCalendar(named)
+		}
+
+		switch options["blackout"] {
+		case "", "skip":
+		case "next-business-day":
+			policy = schedule.BlackoutNextBusinessDay
+		default:
+			return false, fmt.Errorf("Unknown blackout= policy '%s'", options["blackout"])
+		}
+	} else if _, ok := options["blackout"]; ok {
+		return false, fmt.Errorf("blackout= given without calendar=")
+	}
+
+	var from, until time.Time
+	fromValue, hasFrom := options["from"]
+	untilValue, hasUntil := options["until"]
+	if hasFrom || hasUntil {
+		if hasFrom {
+			from, _, err = parseTime(fromValue, s.location)
+			if err != nil {
+				return false, fmt.Errorf("Invalid from= option: %s", err)
+			}
+		}
+
+		if hasUntil {
+			var dateOnly bool
+			until, dateOnly, err = parseTime(untilValue, s.location)
+			if err != nil {
+				return false, fmt.Errorf("Invalid until= option: %s", err)
+			}
+
+			// a bare date means "until the end of that day"
+			if dateOnly {
+				until = until.AddDate(0, 0, 1)
+			}
+		}
+
+		if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+			return false, fmt.Errorf("from= (%s) must be earlier than until= (%s)",
+				fromValue, untilValue)
+		}
+	}
+
+	jitter := s.splay
+	if value, ok := options["jitter"]; ok {
+		jitter, err = time.ParseDuration(value)
+		if err != nil {
+			return false, fmt.Errorf("Invalid jitter= option: %s", err)
+		}
+
+		if jitter < 0 {
+			return false, fmt.Errorf("Invalid jitter= option: must not be negative")
+		}
+	}
+
+	// each member of a group has its own entry, so is hashed, retried,
+	// suppressed, and jittered, independently of the others
+	for _, instance := range instances {
+		member := *e
+		member.task = instance.task
+		member.parameters = instance.parameters
+
+		resolved, err := ResolveHashed(matches[1], member.task)
+		if err != nil {
+			return false, err
+		}
+
+		expr, err := cronexpr.Parse(resolved)
+		if expr == nil {
+			return false, fmt.Errorf("Failed to parse cron expression: %s", err)
+		}
+
+		var nexter schedule.Nexter = expr
+		if calendar != nil {
+			nexter = schedule.NewBlackoutNexter(nexter, calendar, policy)
+		}
+
+		if hasFrom || hasUntil {
+			member.bounded = schedule.NewBoundedNexter(nexter, from, until)
+			nexter = member.bounded
+		}
+
+		if jitter >= time.Second {
+			nexter = schedule.NewJitterNexter(nexter, jitter, member.task)
+		}
+
+		s.entries = append(s.entries, &member)
+		s.Add(member.task, nexter)
+		s.prioritize(member.task)
+	}
+
+	return true, nil
+}
+
+// parseGroup starts the definition of a task group
+func (s *Crontab) parseGroup(name string) (bool, error) {
+	if _, ok := s.groups[name]; ok {
+		return false, fmt.Errorf("Group '%s' is already defined", name)
+	}
+
+	if _, ok := s.table[name]; ok {
+		return false, fmt.Errorf("Group '%s' has the same name as a task", name)
+	}
+
+	s.groups[name] = []string{}
+	s.block = name
+
+	return true, nil
+}
+
+// parseMember adds a task to the group being defined
+func (s *Crontab) parseMember(task string) (bool, error) {
+	if _, ok := s.groups[task]; ok {
+		return false, fmt.Errorf("Group '%s' cannot be a member of group '%s'", task, s.block)
+	}
+
+	for _, member := range s.groups[s.block] {
+		if member == task {
+			return false, fmt.Errorf("Task '%s' is already a member of group '%s'", task, s.block)
+		}
+	}
+
+	s.groups[s.block] = append(s.groups[s.block], task)
+
 	return true, nil
 }
 
+// expand returns the tasks named by an entry: a task, a group, or a
+// comma-separated list of either
+func (s *Crontab) expand(names string) ([]string, error) {
+	tasks := []string{}
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(names, ",") {
+		if name == "" {
+			return nil, fmt.Errorf("Empty task name in '%s'", names)
+		}
+
+		members, ok := s.groups[name]
+		if !ok {
+			members = []string{name}
+		} else if len(members) == 0 {
+			return nil, fmt.Errorf("Group '%s' has no members", name)
+		}
+
+		for _, task := range members {
+			if !seen[task] {
+				seen[task] = true
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	return tasks, nil
+}
+
+// SetParameter sets the values over which entries using the parameter (as
+// "{{name}}") are expanded. It must be called prior to Parse/Load of any such
+// entry.
+func (s *Crontab) SetParameter(name string, values []string) error {
+	if _, ok := s.parameters[name]; ok {
+		return fmt.Errorf("Parameter '%s' is already defined", name)
+	}
+
+	if len(values) == 0 {
+		return fmt.Errorf("Parameter '%s' has no values", name)
+	}
+
+	for _, value := range values {
+		if !valueMatcher.MatchString(value) {
+			return fmt.Errorf("Invalid value '%s' of parameter '%s': only letters, numbers, '-' and '_' are allowed",
+				value, name)
+		}
+	}
+
+	s.parameters[name] = values
+
+	return nil
+}
+
+// LoadParameter sets the values of a parameter (see SetParameter), from a
+// list of one value per line
+func (s *Crontab) LoadParameter(name string, r io.Reader) error {
+	values := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ignoredMatcher.MatchString(line) {
+			continue
+		}
+
+		values = append(values, strings.TrimSpace(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return s.SetParameter(name, values)
+}
+
+// bindings returns every combination of the values of the parameters used in
+// the given text
+func (s *Crontab) bindings(text string) ([]map[string]string, error) {
+	bindings := []map[string]string{{}}
+	for _, matches := range placeholderMatcher.FindAllStringSubmatch(text, -1) {
+		name := matches[1]
+		if _, ok := bindings[0][name]; ok {
+			continue
+		}
+
+		values, ok := s.parameters[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown parameter '%s'", name)
+		}
+
+		expanded := []map[string]string{}
+		for _, binding := range bindings {
+			for _, value := range values {
+				combined := map[string]string{name: value}
+				for other, otherValue := range binding {
+					combined[other] = otherValue
+				}
+				expanded = append(expanded, combined)
+			}
+		}
+		bindings = expanded
+	}
+
+	return bindings, nil
+}
+
+// substitute replaces each "{{name}}" in the given text with the value of the
+// parameter
+func substitute(text string, binding map[string]string) string {
+	return placeholderMatcher.ReplaceAllStringFunc(text, func(placeholder string) string {
+		return binding[placeholderMatcher.FindStringSubmatch(placeholder)[1]]
+	})
+}
+
+// instances returns an entry (without options) for each task named by an
+// entry, expanding templates, then groups. Each task of a templated entry
+// has the values of the parameters used in its name.
+func (s *Crontab) instances(names string, afterNames string) ([]*entry, error) {
+	bindings, err := s.bindings(names + " " + afterNames)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []*entry{}
+	expanded := make(map[string]map[string]string)
+	for _, binding := range bindings {
+		tasks, err := s.expand(substitute(names, binding))
+		if err != nil {
+			return nil, err
+		}
+
+		var after []string
+		if afterNames != "" {
+			after, err = s.expand(substitute(afterNames, binding))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid after= option: %s", err)
+			}
+		}
+
+		var parameters map[string]string
+		for _, matches := range placeholderMatcher.FindAllStringSubmatch(names, -1) {
+			if parameters == nil {
+				parameters = make(map[string]string)
+			}
+			parameters[matches[1]] = binding[matches[1]]
+		}
+
+		for _, task := range tasks {
+			if err := checkParameters(task, s.Parameters(task), parameters); err != nil {
+				return nil, err
+			}
+
+			if err := checkParameters(task, expanded[task], parameters); err != nil {
+				return nil, err
+			}
+			expanded[task] = parameters
+
+			instances = append(instances, &entry{task: task, parameters: parameters, after: after})
+		}
+	}
+
+	return instances, nil
+}
+
+// checkParameters returns an error if a task is given different values for
+// any of the parameters
+func checkParameters(task string, existing map[string]string, parameters map[string]string) error {
+	for name, value := range parameters {
+		if other, ok := existing[name]; ok && other != value {
+			return fmt.Errorf("Task '%s' is given both '%s' and '%s' as parameter '%s'", task, other, value, name)
+		}
+	}
+
+	return nil
+}
+
+// Parameters returns the values of the parameters of a task expanded from a
+// templated entry, if any
+func (s *Crontab) Parameters(task string) map[string]string {
+	var parameters map[string]string
+	for _, e := range s.entries {
+		if e.task != task {
+			continue
+		}
+
+		for name, value := range e.parameters {
+			if parameters == nil {
+				parameters = make(map[string]string)
+			}
+			parameters[name] = value
+		}
+	}
+
+	return parameters
+}
+
+// Environment returns the environment variables for a task expanded from a
+// templated entry, if any: one per parameter, named as the parameter in upper
+// case, eg: "TENANT_ID" for "tenant-id"
+func (s *Crontab) Environment(task string) map[string]string {
+	var environment map[string]string
+	for name, value := range s.Parameters(task) {
+		if environment == nil {
+			environment = make(map[string]string)
+		}
+		environment[strings.ToUpper(strings.ReplaceAll(name, "-", "_"))] = value
+	}
+
+	return environment
+}
+
+// Options returns the options of a task, as given on its first entry
+func (s *Crontab) Options(task string) map[string]string {
+	for _, e := range s.entries {
+		if e.task == task {
+			options := make(map[string]string)
+			for name, value := range e.options {
+				options[name] = value
+			}
+
+			return options
+		}
+	}
+
+	return nil
+}
+
+// parseDependency parses an entry which is run after other tasks complete,
+// rather than at a time
+func (s *Crontab) parseDependency(line string, names string, rawOptions string) (bool, error) {
+	options, err := parseOptions(rawOptions)
+	if err != nil {
+		return false, err
+	}
+
+	afterNames, ok := options["after"]
+	if !ok {
+		return false, fmt.Errorf("Unknown crontab line format")
+	}
+
+	for name := range options {
+		switch name {
+		case "after", "tags", "priority", "mutex", "overlap":
+		default:
+			return false, fmt.Errorf("%s= cannot be combined with after=", name)
+		}
+	}
+
+	if afterNames == "" {
+		return false, fmt.Errorf("Empty task in after= option")
+	}
+
+	instances, err := s.instances(names, afterNames)
+	if err != nil {
+		return false, err
+	}
+
+	e := &entry{line: strings.TrimSpace(line), options: options}
+	if err := e.parseCommon(options); err != nil {
+		return false, err
+	}
+
+	for _, instance := range instances {
+		member := *e
+		member.task = instance.task
+		member.parameters = instance.parameters
+		member.after = instance.after
+
+		s.entries = append(s.entries, &member)
+		s.list(member.task)
+		s.prioritize(member.task)
+	}
+
+	return true, nil
+}
+
+// parseCommon parses the options shared by timed and after= entries
+func (e *entry) parseCommon(options map[string]string) error {
+	var err error
+
+	if tags, ok := options["tags"]; ok {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag == "" {
+				return fmt.Errorf("Empty tag in tags= option")
+			}
+
+			e.tags = append(e.tags, tag)
+		}
+	}
+
+	if groups, ok := options["mutex"]; ok {
+		for _, group := range strings.Split(groups, ",") {
+			if group == "" {
+				return fmt.Errorf("Empty group in mutex= option")
+			}
+
+			e.mutex = append(e.mutex, group)
+		}
+	}
+
+	switch options["overlap"] {
+	case "", "skip":
+	case "defer":
+		e.defers = true
+	default:
+		return fmt.Errorf("Unknown overlap= policy '%s'", options["overlap"])
+	}
+
+	if _, ok := options["overlap"]; ok && len(e.mutex) == 0 {
+		return fmt.Errorf("overlap= given without mutex=")
+	}
+
+	if value, ok := options["priority"]; ok {
+		e.priority, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid priority= option: must be a whole number")
+		}
+	}
+
+	return nil
+}
+
+// prioritize sets the priority of a task: the highest of its entries
+func (s *Crontab) prioritize(task string) {
+	var priority int
+	found := false
+	for _, e := range s.entries {
+		if e.task == task && (!found || e.priority > priority) {
+			priority = e.priority
+			found = true
+		}
+	}
+	s.SetPriority(task, priority)
+}
+
+// Validate checks the loaded entries against the given time, returning a
+// warning for each entry which can no longer fire.
+func (s *Crontab) Validate(now time.Time) []error {
+	warnings := []error{}
+
+	for _, e := range s.entries {
+		if e.bounded != nil && e.bounded.Expired(now) {
+			warnings = append(warnings,
+				fmt.Errorf("Entry for task '%s' expired at %v and will never run: %s",
+					e.task, e.bounded.Until, e.line))
+		}
+	}
+
+	return warnings
+}
+
+// Tagged returns the (sorted, de-duplicated) names of all tasks with an
+// entry carrying the given tag
+func (s *Crontab) Tagged(tag string) []string {
+	found := make(map[string]bool)
+	for _, e := range s.entries {
+		for _, entryTag := range e.tags {
+			if entryTag == tag {
+				found[e.task] = true
+			}
+		}
+	}
+
+	tasks := make([]string, 0, len(found))
+	for task := range found {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	return tasks
+}
+
+// MutexGroups returns the (sorted, de-duplicated) mutual-exclusion groups of
+// all entries for the given task
+func (s *Crontab) MutexGroups(task string) []string {
+	found := make(map[string]bool)
+	for _, e := range s.entries {
+		if e.task != task {
+			continue
+		}
+
+		for _, group := range e.mutex {
+			found[group] = true
+		}
+	}
+
+	groups := make([]string, 0, len(found))
+	for group := range found {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	return groups
+}
+
+// MutexMembers returns the (sorted, de-duplicated) names of all tasks with an
+// entry in the given mutual-exclusion group
+func (s *Crontab) MutexMembers(group string) []string {
+	found := make(map[string]bool)
+	for _, e := range s.entries {
+		for _, entryGroup := range e.mutex {
+			if entryGroup == group {
+				found[e.task] = true
+			}
+		}
+	}
+
+	tasks := make([]string, 0, len(found))
+	for task := range found {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	return tasks
+}
+
+// DefersOverlap reports whether any entry for the given task has
+// overlap=defer, meaning it should run once its mutual-exclusion groups are
+// clear, rather than being skipped
+func (s *Crontab) DefersOverlap(task string) bool {
+	for _, e := range s.entries {
+		if e.task == task && e.defers {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Dependents returns the (sorted, de-duplicated) names of all tasks with an
+// entry to be run after the given task completes
+func (s *Crontab) Dependents(task string) []string {
+	found := make(map[string]bool)
+	for _, e := range s.entries {
+		for _, after := range e.after {
+			if after == task {
+				found[e.task] = true
+			}
+		}
+	}
+
+	tasks := make([]string, 0, len(found))
+	for task := range found {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	return tasks
+}
+
+// Upstreams returns the (sorted, de-duplicated) names of all tasks which the
+// given task is to be run after, across all of its entries
+func (s *Crontab) Upstreams(task string) []string {
+	found := make(map[string]bool)
+	for _, e := range s.entries {
+		if e.task != task {
+			continue
+		}
+
+		for _, after := range e.after {
+			found[after] = true
+		}
+	}
+
+	tasks := make([]string, 0, len(found))
+	for task := range found {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	return tasks
+}
+
+// checkDependencies returns an error for any after= entry naming an unknown
+// task, or which (indirectly) depends upon itself
+func (s *Crontab) checkDependencies() error {
+	for _, e := range s.entries {
+		for _, after := range e.after {
+			if _, ok := s.table[after]; !ok {
+				return fmt.Errorf("Entry for task '%s' is after unknown task '%s': %s",
+					e.task, after, e.line)
+			}
+		}
+	}
+
+	// depth-first, from each task, looking for a path back to that task
+	var reaches func(from string, to string, seen map[string]bool) bool
+	reaches = func(from string, to string, seen map[string]bool) bool {
+		for _, dependent := range s.Dependents(from) {
+			if dependent == to {
+				return true
+			}
+
+			if !seen[dependent] {
+				seen[dependent] = true
+				if reaches(dependent, to, seen) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	tasks := make([]string, 0, len(s.table))
+	for task := range s.table {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	for _, task := range tasks {
+		if reaches(task, task, make(map[string]bool)) {
+			return fmt.Errorf("Task '%s' is (indirectly) after itself", task)
+		}
+	}
+
+	return nil
+}
+
+func parseOptions(raw string) (map[string]string, error) {
+	options := make(map[string]string)
+
+	for _, field := range strings.Fields(raw) {
+		matches := optionMatcher.FindStringSubmatch(field)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("Invalid option '%s'", field)
+		}
+
+		switch matches[1] {
+		case "from", "until", "calendar", "blackout", "tags", "jitter", "priority", "mutex", "overlap", "after":
+		default:
+			return nil, fmt.Errorf("Unknown option '%s'", matches[1])
+		}
+
+		if _, ok := options[matches[1]]; ok {
+			return nil, fmt.Errorf("Option '%s' given more than once", matches[1])
+		}
+
+		options[matches[1]] = matches[2]
+	}
+
+	return options, nil
+}
+
+// parseTime parses a date or date-time in the given location, additionally
+// reporting whether only a date (with no time) was given.
+func parseTime(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(dateFormat, value, location); err == nil {
+		return t, true, nil
+	}
+
+	for _, format := range dateTimeFormats {
+		if t, err := time.ParseInLocation(format, value, location); err == nil {
+			return t, false, nil
+		}
+	}
+
+	return time.Time{}, false,
+		fmt.Errorf("'%s' is not in YYYY-MM-DD or YYYY-MM-DDTHH:mm[:ss] format", value)
+}
+
 func (s *Crontab) Load(r io.Reader) (bool, error) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -114,5 +965,9 @@ func (s *Crontab) Load(r io.Reader) (bool, error) {
 		return false, err
 	}
 
+	if err := s.checkDependencies(); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
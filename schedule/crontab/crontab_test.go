@@ -5,6 +5,10 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorhill/cronexpr"
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
 )
 
 func TestCronTab(t *testing.T) {
@@ -105,4 +109,548 @@ func TestCronTab(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Task names may contain '=', with options after them", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, err := tab.Parse("* * * * * mode=full priority=2 # nightly"); !ok {
+			t.Fatalf("Parsing a task name containing '=' did not succeed: %s", err)
+		}
+
+		if tasks := tab.Tasks(); len(tasks) != 1 || tasks[0] != "mode=full" {
+			t.Fatalf("Task name containing '=' was not kept whole: %v", tasks)
+		}
+
+		if priority := tab.Priority("mode=full"); priority != 2 {
+			t.Fatalf("Options after a task name containing '=' were not parsed: priority %d", priority)
+		}
+	})
+
+	t.Run("Task names containing '=' should be reported in after= entries", func(t *testing.T) {
+		tab := NewCrontab()
+		_, err := tab.Parse("mode=full after=extract")
+		if err == nil || !strings.Contains(err.Error(), "cannot contain '='") {
+			t.Fatalf("after= entry for a task name containing '=' was not clearly refused: %v", err)
+		}
+	})
+}
+
+func TestCronTabActiveWindow(t *testing.T) {
+	t.Run("from= and until= should bound the entry", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, err := tab.Parse("0 * * * * Example from=2006-01-02T12:00 until=2006-01-03"); !ok {
+			t.Fatalf("Parsing a line with from= and until= did not succeed: %s", err)
+		}
+
+		next := tab.Next(time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC))
+		if !next.Equal(time.Date(2006, 1, 2, 12, 0, 0, 0, time.UTC)) {
+			t.Fatalf("from= did not delay the first run: %v", next)
+		}
+
+		next = tab.Next(time.Date(2006, 1, 3, 22, 0, 0, 0, time.UTC))
+		if !next.Equal(time.Date(2006, 1, 3, 23, 0, 0, 0, time.UTC)) {
+			t.Fatalf("until= with a bare date did not include the whole day")
+		}
+
+		next = tab.Next(time.Date(2006, 1, 3, 23, 0, 0, 0, time.UTC))
+		if !next.IsZero() {
+			t.Fatalf("until= did not end the schedule: %v", next)
+		}
+	})
+
+	t.Run("until= should be evaluated in the Crontab location", func(t *testing.T) {
+		location := time.FixedZone("Test", 2*60*60)
+		tab := NewCrontab()
+		tab.SetLocation(location)
+		if ok, err := tab.Parse("0 * * * * Example until=2006-01-02T12:00"); !ok {
+			t.Fatalf("Parsing a line with until= did not succeed: %s", err)
+		}
+
+		next := tab.Next(time.Date(2006, 1, 2, 10, 0, 0, 0, location))
+		if !next.Equal(time.Date(2006, 1, 2, 11, 0, 0, 0, location)) {
+			t.Fatalf("Next was unexpectedly bounded: %v", next)
+		}
+
+		next = tab.Next(time.Date(2006, 1, 2, 11, 0, 0, 0, location))
+		if !next.IsZero() {
+			t.Fatalf("until= was not evaluated in the Crontab location: %v", next)
+		}
+	})
+
+	t.Run("Invalid options should fail", func(t *testing.T) {
+		for _, line := range []string{
+			"* * * * * Example from=yesterday",
+			"* * * * * Example until=",
+			"* * * * * Example from=2006-01-03 until=2006-01-02",
+			"* * * * * Example from=2006-01-02 from=2006-01-03",
+			"* * * * * Example unknown=1",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Parse(line); ok {
+				t.Fatalf("Parsing an invalid line succeeded: %s", line)
+			}
+		}
+	})
+
+	t.Run("Validate should warn about expired entries", func(t *testing.T) {
+		tab := NewCrontab()
+		_, _ = tab.Load(strings.NewReader(
+			"* * * * * Expired until=2006-01-01\n" +
+				"* * * * * Current from=2006-01-01\n" +
+				"* * * * * Forever\n"))
+
+		warnings := tab.Validate(time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC))
+		if len(warnings) != 1 {
+			t.Fatalf("Validate did not return exactly one warning: %v", warnings)
+		}
+
+		if !strings.Contains(warnings[0].Error(), "Expired") {
+			t.Fatalf("Validate warning did not name the expired task: %s", warnings[0])
+		}
+	})
+}
+
+func TestCronTabCalendar(t *testing.T) {
+	// Friday 2006-01-06 is a holiday
+	holidays := schedule.NewCalendar()
+	holidays.Add(2006, time.January, 6)
+
+	t.Run("calendar= should skip blackout dates", func(t *testing.T) {
+		tab := NewCrontab()
+		tab.SetCalendar("holidays", holidays)
+		if ok, err := tab.Parse("0 9 * * 5 Example calendar=holidays"); !ok {
+			t.Fatalf("Parsing a line with calendar= did not succeed: %s", err)
+		}
+
+		next := tab.Next(time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC))
+		if !next.Equal(time.Date(2006, 1, 13, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("calendar= did not skip the blackout date: %v", next)
+		}
+	})
+
+	t.Run("blackout=next-business-day should move blackout dates", func(t *testing.T) {
+		tab := NewCrontab()
+		tab.SetCalendar("holidays", holidays)
+		if ok, err := tab.Parse("0 9 * * 5 Example calendar=holidays blackout=next-business-day"); !ok {
+			t.Fatalf("Parsing a line with blackout= did not succeed: %s", err)
+		}
+
+		next := tab.Next(time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC))
+		if !next.Equal(time.Date(2006, 1, 9, 9, 0, 0, 0, time.UTC)) {
+			t.Fatalf("blackout= did not move the run to the next business day: %v", next)
+		}
+	})
+
+	t.Run("Invalid calendar options should fail", func(t *testing.T) {
+		for _, line := range []string{
+			"* * * * * Example calendar=unknown",
+			"* * * * * Example calendar=holidays blackout=sometimes",
+			"* * * * * Example blackout=skip",
+		} {
+			tab := NewCrontab()
+			tab.SetCalendar("holidays", holidays)
+			if ok, _ := tab.Parse(line); ok {
+				t.Fatalf("Parsing an invalid line succeeded: %s", line)
+			}
+		}
+	})
+}
+
+func TestCronTabTags(t *testing.T) {
+	t.Run("Tagged should return tasks carrying the tag", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"0 * * * * Billing tags=finance,nightly\n" +
+				"30 * * * * Billing tags=finance\n" +
+				"0 * * * * Invoices tags=finance\n" +
+				"0 * * * * Other tags=nightly\n" +
+				"0 * * * * Untagged\n"))
+		if !ok {
+			t.Fatalf("Parsing lines with tags= did not succeed: %s", err)
+		}
+
+		tasks := tab.Tagged("finance")
+		if strings.Join(tasks, ",") != "Billing,Invoices" {
+			t.Fatalf("Tagged did not return the expected tasks: %v", tasks)
+		}
+
+		if len(tab.Tagged("unknown")) != 0 {
+			t.Fatalf("Tagged returned tasks for an unknown tag")
+		}
+	})
+
+	t.Run("Empty tags should fail", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, _ := tab.Parse("* * * * * Example tags=a,,b"); ok {
+			t.Fatalf("Parsing an empty tag succeeded")
+		}
+	})
+}
+
+func TestCronTabJitter(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	t.Run("jitter= should delay runs within bounds", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, err := tab.Parse("0 * * * * Example jitter=10m"); !ok {
+			t.Fatalf("Parsing a line with jitter= did not succeed: %s", err)
+		}
+
+		delayed := 0
+		next := start
+		for i := 0; i < 24; i++ {
+			next = tab.Next(next)
+			if next.Minute() >= 10 {
+				t.Fatalf("jitter= delayed a run beyond its bound: %v", next)
+			}
+
+			if next.Minute() != 0 || next.Second() != 0 {
+				delayed += 1
+			}
+		}
+
+		if delayed == 0 {
+			t.Fatalf("jitter= did not delay any runs")
+		}
+	})
+
+	t.Run("SetSplay should apply to entries without jitter=", func(t *testing.T) {
+		tab := NewCrontab()
+		tab.SetSplay(10 * time.Minute)
+		_, _ = tab.Load(strings.NewReader(
+			"0 * * * * Splayed\n" +
+				"0 * * * * Exact jitter=0s\n"))
+
+		exact := 0
+		next := start
+		for i := 0; i < 48; i++ {
+			next = tab.Next(next)
+			if next.Minute() == 0 && next.Second() == 0 {
+				exact += 1
+			}
+		}
+
+		if exact < 24 || exact == 48 {
+			t.Fatalf("SetSplay and jitter=0s did not combine as expected: %d", exact)
+		}
+	})
+
+	t.Run("Invalid jitter= should fail", func(t *testing.T) {
+		for _, line := range []string{
+			"* * * * * Example jitter=soon",
+			"* * * * * Example jitter=-1m",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Parse(line); ok {
+				t.Fatalf("Parsing an invalid line succeeded: %s", line)
+			}
+		}
+	})
+}
+
+func TestCronTabPriority(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	t.Run("Tick should launch tasks in priority order, then name order", func(t *testing.T) {
+		tab := NewCrontab()
+		_, _ = tab.Load(strings.NewReader(
+			"0 * * * * Low priority=-1\n" +
+				"0 * * * * B\n" +
+				"0 * * * * A\n" +
+				"0 * * * * High priority=10\n" +
+				"30 * * * * Both priority=5\n" +
+				"0 * * * * Both\n"))
+
+		order := []string{}
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			order = append(order, task)
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		if _, err := tab.Tick(runner, at); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if strings.Join(order, ",") != "High,Both,A,B,Low" {
+			t.Fatalf("Tasks were not launched in priority order: %v", order)
+		}
+	})
+
+	t.Run("Invalid priority= should fail", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, _ := tab.Parse("* * * * * Example priority=high"); ok {
+			t.Fatalf("Parsing an invalid priority succeeded")
+		}
+	})
+}
+
+func TestCronTabMutex(t *testing.T) {
+	t.Run("Mutex groups should be looked up by task and by group", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"0 * * * * RebuildA mutex=index,cache\n" +
+				"30 * * * * RebuildA mutex=index overlap=defer\n" +
+				"0 * * * * RebuildB mutex=index\n" +
+				"0 * * * * Other\n"))
+		if !ok {
+			t.Fatalf("Parsing lines with mutex= did not succeed: %s", err)
+		}
+
+		if groups := tab.MutexGroups("RebuildA"); strings.Join(groups, ",") != "cache,index" {
+			t.Fatalf("MutexGroups did not return the expected groups: %v", groups)
+		}
+
+		if members := tab.MutexMembers("index"); strings.Join(members, ",") != "RebuildA,RebuildB" {
+			t.Fatalf("MutexMembers did not return the expected tasks: %v", members)
+		}
+
+		if !tab.DefersOverlap("RebuildA") || tab.DefersOverlap("RebuildB") {
+			t.Fatalf("DefersOverlap did not reflect overlap=defer")
+		}
+	})
+
+	t.Run("Invalid mutex options should fail", func(t *testing.T) {
+		for _, line := range []string{
+			"* * * * * Example mutex=a,,b",
+			"* * * * * Example mutex=a overlap=wait",
+			"* * * * * Example overlap=defer",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Parse(line); ok {
+				t.Fatalf("Parsing an invalid line succeeded: %s", line)
+			}
+		}
+	})
+}
+
+func TestCronTabAfter(t *testing.T) {
+	t.Run("Dependents should be looked up by the task they are after", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"0 2 * * * Extract\n" +
+				"Load after=Extract priority=5\n" +
+				"Report after=Load,Extract # both\n"))
+		if !ok {
+			t.Fatalf("Parsing lines with after= did not succeed: %s", err)
+		}
+
+		if dependents := tab.Dependents("Extract"); strings.Join(dependents, ",") != "Load,Report" {
+			t.Fatalf("Dependents did not return the expected tasks: %v", dependents)
+		}
+
+		if upstreams := tab.Upstreams("Report"); strings.Join(upstreams, ",") != "Extract,Load" {
+			t.Fatalf("Upstreams did not return the expected tasks: %v", upstreams)
+		}
+
+		if _, ok := tab.Nexter("Load"); !ok {
+			t.Fatalf("A task with only an after= entry was not known")
+		}
+
+		if next := tab.Next(time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC)) {
+			t.Fatalf("An after= entry affected the time of the next tick: %v", next)
+		}
+
+		if priority := tab.Priority("Load"); priority != 5 {
+			t.Fatalf("Options were not applied to an after= entry: priority %d", priority)
+		}
+	})
+
+	t.Run("Invalid after= entries should fail", func(t *testing.T) {
+		for _, text := range []string{
+			"* * * * * Example after=Other\n* * * * * Other\n",
+			"Example after=Other jitter=5m\n* * * * * Other\n",
+			"Example after=\n",
+			"Example after=Unknown\n",
+			"* * * * * A\nB after=A,C\nC after=B\n",
+			"Example\n",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Load(strings.NewReader(text)); ok {
+				t.Fatalf("Loading an invalid crontab succeeded: %q", text)
+			}
+		}
+	})
+}
+
+func TestCronTabGroups(t *testing.T) {
+	t.Run("A group should launch every member, with a result for each", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"[group nightly]\n" +
+				"TenantA\n" +
+				"# comments are allowed\n" +
+				"TenantB\n" +
+				"0 2 * * * nightly tags=tenants\n" +
+				"0 3 * * * Extra,TenantA\n" +
+				"Report after=nightly\n"))
+		if !ok {
+			t.Fatalf("Parsing a group did not succeed: %s", err)
+		}
+
+		at := time.Date(2006, 1, 2, 2, 0, 0, 0, time.UTC)
+		launched := []string{}
+		results, err := tab.Tick(taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			launched = append(launched, task)
+			return &taskrunner.TaskStatus{Ran: task == "TenantA"}, nil
+		}), at)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if strings.Join(launched, ",") != "TenantA,TenantB" {
+			t.Fatalf("Every member was not launched: %v", launched)
+		}
+
+		if len(results) != 2 || !results["TenantA"].Ran || results["TenantB"].Ran {
+			t.Fatalf("Results were not given per member: %v", results)
+		}
+
+		if tasks := tab.Tagged("tenants"); strings.Join(tasks, ",") != "TenantA,TenantB" {
+			t.Fatalf("Options were not applied to every member: %v", tasks)
+		}
+
+		if next := tab.Next(at); !next.Equal(at.Add(time.Hour)) {
+			t.Fatalf("A comma-separated list was not scheduled: %v", next)
+		}
+
+		if dependents := tab.Dependents("TenantB"); strings.Join(dependents, ",") != "Report" {
+			t.Fatalf("after= did not expand the group: %v", dependents)
+		}
+
+		if _, ok := tab.Nexter("nightly"); ok {
+			t.Fatalf("The group was itself scheduled as a task")
+		}
+	})
+
+	t.Run("Invalid groups should fail", func(t *testing.T) {
+		for _, text := range []string{
+			"[group nightly]\n0 2 * * * nightly\n",
+			"[group nightly]\nA\n[group nightly]\nB\n",
+			"* * * * * A\n[group A]\nB\n",
+			"[group inner]\nA\n[group outer]\ninner\n",
+			"[group nightly]\nA\nA\n",
+			"* * * * * A,,B\n",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Load(strings.NewReader(text)); ok {
+				t.Fatalf("Loading an invalid crontab succeeded: %q", text)
+			}
+		}
+	})
+
+	t.Run("H should be resolved for each member", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"[group spread]\n" +
+				"x1\n" +
+				"x2\n" +
+				"H * * * * spread\n" +
+				"H * * * * y1,y2\n"))
+		if !ok {
+			t.Fatalf("Parsing hashed group entries did not succeed: %s", err)
+		}
+
+		assertHashedPerTask(t, tab, "H * * * *", "x1", "x2")
+		assertHashedPerTask(t, tab, "H * * * *", "y1", "y2")
+	})
+}
+
+func TestCronTabTemplates(t *testing.T) {
+	t.Run("Templated entries should be expanded over each value", func(t *testing.T) {
+		tab := NewCrontab()
+		if err := tab.LoadParameter("region", strings.NewReader("# regions\neu\nus\n")); err != nil {
+			t.Fatalf("Loading parameter values did not succeed: %s", err)
+		}
+
+		ok, err := tab.Load(strings.NewReader(
+			"tenant=a,b\n" +
+				"0 2 * * * report-{{tenant}}-{{region}}\n" +
+				"0 1 * * * extract-{{tenant}}\n" +
+				"load-{{tenant}} after=extract-{{tenant}}\n"))
+		if !ok {
+			t.Fatalf("Parsing templated entries did not succeed: %s", err)
+		}
+
+		expected := "extract-a,extract-b,load-a,load-b,report-a-eu,report-a-us,report-b-eu,report-b-us"
+		if tasks := tab.Tasks(); strings.Join(tasks, ",") != expected {
+			t.Fatalf("Entries were not expanded as expected: %v", tasks)
+		}
+
+		environment := tab.Environment("report-b-us")
+		if len(environment) != 2 || environment["TENANT"] != "b" || environment["REGION"] != "us" {
+			t.Fatalf("Unexpected environment: %v", environment)
+		}
+
+		if dependents := tab.Dependents("extract-a"); strings.Join(dependents, ",") != "load-a" {
+			t.Fatalf("after= was not expanded with the same value: %v", dependents)
+		}
+
+		if environment := tab.Environment("load-a"); environment["TENANT"] != "a" {
+			t.Fatalf("Unexpected environment of an after= entry: %v", environment)
+		}
+
+		if parameters := tab.Parameters("report-a-eu"); len(parameters) != 2 || parameters["region"] != "eu" {
+			t.Fatalf("Unexpected parameters: %v", parameters)
+		}
+
+		if options := tab.Options("load-b"); options["after"] != "extract-{{tenant}}" {
+			t.Fatalf("Unexpected options: %v", options)
+		}
+	})
+
+	t.Run("Invalid templates and parameters should fail", func(t *testing.T) {
+		for _, text := range []string{
+			"0 2 * * * report-{{tenant}}\n",
+			"tenant=\n",
+			"tenant=a,,b\n",
+			"tenant=a\ntenant=b\n",
+			"tenant=a.b\n",
+			"tenant=a,a-b\nsuffix=b-c,c\n0 2 * * * report-{{tenant}}-{{suffix}}\n",
+			"tenant=a,report-a\n0 2 * * * report-{{tenant}}\n0 3 * * * {{tenant}}\n",
+		} {
+			tab := NewCrontab()
+			if ok, _ := tab.Load(strings.NewReader(text)); ok {
+				t.Fatalf("Loading an invalid crontab succeeded: %q", text)
+			}
+		}
+	})
+
+	t.Run("H should be resolved for each expanded task", func(t *testing.T) {
+		tab := NewCrontab()
+		ok, err := tab.Load(strings.NewReader(
+			"tenant=a,b\n" +
+				"H * * * * report-{{tenant}}\n"))
+		if !ok {
+			t.Fatalf("Parsing a hashed templated entry did not succeed: %s", err)
+		}
+
+		assertHashedPerTask(t, tab, "H * * * *", "report-a", "report-b")
+	})
+}
+
+// assertHashedPerTask checks that each task is scheduled as if its entry named
+// it alone, and that (for these tasks) the hashed minutes differ
+func assertHashedPerTask(t *testing.T, tab *Crontab, expr string, tasks ...string) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	minutes := map[int]bool{}
+	for _, task := range tasks {
+		resolved, err := ResolveHashed(expr, task)
+		if err != nil {
+			t.Fatalf("Unexpected error resolving: %s", err)
+		}
+
+		nexter, ok := tab.Nexter(task)
+		if !ok {
+			t.Fatalf("Task '%s' was not scheduled", task)
+		}
+
+		next := nexter.Next(at)
+		if expected := cronexpr.MustParse(resolved).Next(at); !next.Equal(expected) {
+			t.Fatalf("Task '%s' was not hashed by its own name: %v, expected %v", task, next, expected)
+		}
+		minutes[next.Minute()] = true
+	}
+
+	if len(minutes) != len(tasks) {
+		t.Fatalf("Tasks %v were all hashed to the same minute", tasks)
+	}
 }
@@ -0,0 +1,106 @@
+package crontab
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hashedMatcher *regexp.Regexp
+
+func init() {
+	hashedMatcher = regexp.MustCompile("^H(?:\\((\\d+)-(\\d+)\\))?(?:/(\\d+))?$")
+}
+
+// ResolveHashed replaces any "H" tokens in the minute and hour fields of a
+// cron expression with a value derived from a hash of the task name, so that
+// tasks sharing an expression are spread out, but each task always runs at
+// the same time. The supported forms (shown for minutes) are:
+//
+//	H          a single minute within 0-59
+//	H(0-29)    a single minute within 0-29
+//	H/15       every 15 minutes, starting from a minute within 0-14
+//	H(0-29)/10 every 10 minutes within 0-29, starting from a minute within 0-9
+func ResolveHashed(expr string, task string) (string, error) {
+	if strings.HasPrefix(expr, "@") || !strings.Contains(expr, "H") {
+		return expr, nil
+	}
+
+	fields := strings.Fields(expr)
+
+	// with seven fields, the first is seconds
+	minute := 0
+	if len(fields) == 7 {
+		minute = 1
+	}
+
+	sum := md5.Sum([]byte(task))
+	limits := []struct {
+		index int
+		max   int
+		hash  uint32
+	}{
+		{minute, 59, binary.BigEndian.Uint32(sum[0:4])},
+		{minute + 1, 23, binary.BigEndian.Uint32(sum[4:8])},
+	}
+
+	for _, limit := range limits {
+		if limit.index >= len(fields) {
+			continue
+		}
+
+		parts := strings.Split(fields[limit.index], ",")
+		for i, part := range parts {
+			if !strings.HasPrefix(part, "H") {
+				continue
+			}
+
+			resolved, err := resolveHashedPart(part, limit.max, limit.hash)
+			if err != nil {
+				return expr, err
+			}
+
+			parts[i] = resolved
+		}
+
+		fields[limit.index] = strings.Join(parts, ",")
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+func resolveHashedPart(part string, max int, hash uint32) (string, error) {
+	matches := hashedMatcher.FindStringSubmatch(part)
+	if len(matches) == 0 {
+		return part, fmt.Errorf("Invalid hashed field '%s'", part)
+	}
+
+	low, high := 0, max
+	if matches[1] != "" {
+		low, _ = strconv.Atoi(matches[1])
+		high, _ = strconv.Atoi(matches[2])
+
+		if low > high || high > max {
+			return part, fmt.Errorf("Invalid range in hashed field '%s'", part)
+		}
+	}
+
+	span := high - low + 1
+	if matches[3] == "" {
+		return strconv.Itoa(low + int(hash%uint32(span))), nil
+	}
+
+	step, _ := strconv.Atoi(matches[3])
+	if step < 1 {
+		return part, fmt.Errorf("Invalid step in hashed field '%s'", part)
+	}
+
+	if step < span {
+		span = step
+	}
+
+	return fmt.Sprintf("%d-%d/%d", low+int(hash%uint32(span)), high, step), nil
+}
@@ -0,0 +1,116 @@
+package crontab
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveHashed(t *testing.T) {
+	t.Run("Expressions without H should be unchanged", func(t *testing.T) {
+		for _, expr := range []string{"*/5 9-17 * * 1-5", "@hourly"} {
+			resolved, err := ResolveHashed(expr, "Example")
+			if err != nil || resolved != expr {
+				t.Fatalf("Expression without H was changed: %s -> %s (%v)", expr, resolved, err)
+			}
+		}
+	})
+
+	t.Run("H should resolve to a stable value within range", func(t *testing.T) {
+		resolved, err := ResolveHashed("H H * * *", "Example")
+		if err != nil {
+			t.Fatalf("Resolving a valid expression failed: %s", err)
+		}
+
+		again, _ := ResolveHashed("H H * * *", "Example")
+		if resolved != again {
+			t.Fatalf("Resolving the same task twice gave different results: %s vs %s", resolved, again)
+		}
+
+		fields := strings.Fields(resolved)
+		minute, err := strconv.Atoi(fields[0])
+		if err != nil || minute < 0 || minute > 59 {
+			t.Fatalf("H did not resolve to a valid minute: %s", resolved)
+		}
+
+		hour, err := strconv.Atoi(fields[1])
+		if err != nil || hour < 0 || hour > 23 {
+			t.Fatalf("H did not resolve to a valid hour: %s", resolved)
+		}
+
+		if strings.Join(fields[2:], " ") != "* * *" {
+			t.Fatalf("Fields other than minute/hour were changed: %s", resolved)
+		}
+	})
+
+	t.Run("H should spread different tasks", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := 0; i < 20; i++ {
+			resolved, _ := ResolveHashed("H * * * *", "Example"+strconv.Itoa(i))
+			seen[resolved] = true
+		}
+
+		if len(seen) < 5 {
+			t.Fatalf("20 tasks resolved to only %d distinct minutes", len(seen))
+		}
+	})
+
+	t.Run("Ranges and steps should be respected", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			task := "Example" + strconv.Itoa(i)
+			resolved, err := ResolveHashed("H(0-29)/10 H(9-17) * * *", task)
+			if err != nil {
+				t.Fatalf("Resolving a valid expression failed: %s", err)
+			}
+
+			fields := strings.Fields(resolved)
+			if !strings.HasSuffix(fields[0], "-29/10") {
+				t.Fatalf("H(0-29)/10 did not resolve to a stepped range: %s", resolved)
+			}
+
+			start, _ := strconv.Atoi(strings.Split(fields[0], "-")[0])
+			if start < 0 || start >= 10 {
+				t.Fatalf("H(0-29)/10 resolved to an out-of-range start: %s", resolved)
+			}
+
+			hour, _ := strconv.Atoi(fields[1])
+			if hour < 9 || hour > 17 {
+				t.Fatalf("H(9-17) resolved to an out-of-range hour: %s", resolved)
+			}
+		}
+	})
+
+	t.Run("Seven-field expressions should resolve minutes, not seconds", func(t *testing.T) {
+		resolved, err := ResolveHashed("0 H * * * * *", "Example")
+		if err != nil {
+			t.Fatalf("Resolving a valid expression failed: %s", err)
+		}
+
+		if !strings.HasPrefix(resolved, "0 ") || strings.Contains(resolved, "H") {
+			t.Fatalf("Seven-field expression was not resolved as expected: %s", resolved)
+		}
+	})
+
+	t.Run("Invalid hashed fields should fail", func(t *testing.T) {
+		for _, expr := range []string{"H(30-0) * * * *", "H(0-60) * * * *", "H/0 * * * *", "HH * * * *"} {
+			if _, err := ResolveHashed(expr, "Example"); err == nil {
+				t.Fatalf("Resolving an invalid expression succeeded: %s", expr)
+			}
+		}
+	})
+
+	t.Run("Crontab should schedule hashed entries", func(t *testing.T) {
+		tab := NewCrontab()
+		if ok, err := tab.Parse("H(0-29)/10 * * * * Example"); !ok {
+			t.Fatalf("Parsing a hashed line did not succeed: %s", err)
+		}
+
+		after := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC).Add(time.Duration(-1))
+		first := tab.Next(after)
+		second := tab.Next(first)
+		if second.Sub(first) != 10*time.Minute || first.Minute() >= 10 {
+			t.Fatalf("Hashed entry was not scheduled as expected: %v, %v", first, second)
+		}
+	})
+}
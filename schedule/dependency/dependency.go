@@ -0,0 +1,361 @@
+package dependency
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+// A Lookup gives the tasks to be run after each task completes, and the tasks
+// each is to be run after, eg: a Crontab
+type Lookup interface {
+	Dependents(task string) []string
+	Upstreams(task string) []string
+}
+
+// A Tracker follows launched tasks through to completion, eg: via ECS
+// DescribeTasks
+type Tracker interface {
+	// Launched returns an identifier for each copy of a task started by a run
+	Launched(status *taskrunner.TaskStatus) []string
+
+	// Stopped returns an entry for each of the given identifiers which has
+	// stopped: nil if it succeeded, otherwise the reason it failed
+	Stopped(ids []string) (map[string]error, error)
+}
+
+// The Warning given for any task which is not run because the task it depends
+// upon did not succeed
+type UpstreamFailedError struct {
+	Task   string
+	After  string
+	Reason error
+}
+
+func (e *UpstreamFailedError) Error() string {
+	return fmt.Sprintf("Skipping Task '%s', as '%s' did not succeed: %s",
+		e.Task, e.After, e.Reason)
+}
+
+func (e *UpstreamFailedError) Unwrap() error {
+	return e.Reason
+}
+
+func (e *UpstreamFailedError) Deliberate() {}
+
+type TriggeredInfo struct {
+	// the task whose completion triggered the run (the last of them, when
+	// run after several)
+	After string
+}
+
+// A DependencySchedule wraps another Schedule, additionally running the
+// dependents of each task (see Lookup) at the first whole-minute after it
+// completes successfully. A dependent of several tasks is run once all of
+// them have succeeded since it was last run (or skipped), and is skipped as
+// soon as any of them fails.
+type DependencySchedule struct {
+	schedule schedule.Schedule
+	lookup   Lookup
+	tracker  Tracker
+	observe  func(err error)
+
+	mu sync.Mutex
+
+	// the task of each launched copy which has not yet been seen to stop
+	awaiting map[string]string
+
+	// the tasks which have succeeded, for each dependent still waiting for
+	// others
+	satisfied map[string]map[string]bool
+}
+
+// NewDependencySchedule wraps a Schedule. observe, if not nil, is called with
+// any error in checking for completed tasks, which are checked again at the
+// next whole-minute.
+func NewDependencySchedule(schedule schedule.Schedule, lookup Lookup, tracker Tracker, observe func(err error)) *DependencySchedule {
+	return &DependencySchedule{
+		schedule:  schedule,
+		lookup:    lookup,
+		tracker:   tracker,
+		observe:   observe,
+		awaiting:  make(map[string]string),
+		satisfied: make(map[string]map[string]bool),
+	}
+}
+
+// Awaiting returns the launched copies of tasks with dependents which have not
+// yet been seen to stop, and the task of each
+func (d *DependencySchedule) Awaiting() map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	awaiting := make(map[string]string)
+	for id, task := range d.awaiting {
+		awaiting[id] = task
+	}
+
+	return awaiting
+}
+
+// Restore replaces the awaited tasks, eg: as returned by Awaiting before a
+// restart
+func (d *DependencySchedule) Restore(awaiting map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.awaiting = make(map[string]string)
+	for id, task := range awaiting {
+		d.awaiting[id] = task
+	}
+}
+
+// Satisfied returns the (sorted) tasks which have succeeded, for each dependent
+// still waiting for others
+func (d *DependencySchedule) Satisfied() map[string][]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	satisfied := make(map[string][]string)
+	for task, upstreams := range d.satisfied {
+		for upstream := range upstreams {
+			satisfied[task] = append(satisfied[task], upstream)
+		}
+		sort.Strings(satisfied[task])
+	}
+
+	return satisfied
+}
+
+// RestoreSatisfied replaces the succeeded tasks of each dependent, eg: as
+// returned by Satisfied before a restart
+func (d *DependencySchedule) RestoreSatisfied(satisfied map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.satisfied = make(map[string]map[string]bool)
+	for task, upstreams := range satisfied {
+		d.satisfied[task] = make(map[string]bool)
+		for _, upstream := range upstreams {
+			d.satisfied[task][upstream] = true
+		}
+	}
+}
+
+// satisfy records that a task has succeeded, returning true if a dependent of
+// it has now seen all of its tasks succeed
+func (d *DependencySchedule) satisfy(task string, after string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.satisfied[task] == nil {
+		d.satisfied[task] = make(map[string]bool)
+	}
+	d.satisfied[task][after] = true
+
+	for _, upstream := range d.lookup.Upstreams(task) {
+		if !d.satisfied[task][upstream] {
+			return false
+		}
+	}
+
+	delete(d.satisfied, task)
+	return true
+}
+
+// reset forgets the succeeded tasks of a dependent, once it is run or skipped
+func (d *DependencySchedule) reset(task string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.satisfied, task)
+}
+
+func (d *DependencySchedule) Next(from time.Time) time.Time {
+	next := d.schedule.Next(from)
+
+	d.mu.Lock()
+	awaiting := len(d.awaiting)
+	d.mu.Unlock()
+	if awaiting == 0 {
+		return next
+	}
+
+	// check again at the next whole-minute
+	check := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(),
+		from.Minute(), 0, 0, from.Location()).Add(time.Minute)
+	if next.IsZero() || check.Before(next) {
+		next = check
+	}
+
+	return next
+}
+
+// Wrap returns a TaskRunner which records each successful run of a task with
+// dependents, so that they can be run once it completes. Every launch should
+// pass through it, including retries and those requested on-demand.
+func (d *DependencySchedule) Wrap(runner taskrunner.TaskRunner) taskrunner.TaskRunner {
+	return taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+		status, err := runner.RunTask(task)
+		if err != nil || !status.Ran || len(d.lookup.Dependents(task)) == 0 {
+			return status, err
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, id := range d.tracker.Launched(status) {
+			d.awaiting[id] = task
+		}
+
+		return status, err
+	})
+}
+
+// completed checks the awaited tasks, returning the reason (or nil) for each
+// which has stopped, keyed by identifier
+func (d *DependencySchedule) completed() map[string]error {
+	d.mu.Lock()
+	ids := make([]string, 0, len(d.awaiting))
+	for id := range d.awaiting {
+		ids = append(ids, id)
+	}
+	d.mu.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sort.Strings(ids)
+	stopped, err := d.tracker.Stopped(ids)
+	if err != nil {
+		if d.observe != nil {
+			d.observe(err)
+		}
+		return nil
+	}
+
+	return stopped
+}
+
+func (d *DependencySchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[string]*taskrunner.TaskStatus, error) {
+	// as with maintenance catch-ups, a dependent which is also scheduled this
+	// tick is only run once
+	suppressor := suppression.NewSuppressionTaskRunner(runner)
+	runstatus := make(map[string]*taskrunner.TaskStatus)
+
+	stopped := d.completed()
+	ids := make([]string, 0, len(stopped))
+	for id := range stopped {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// a dependent skipped because its upstream failed is only reported as
+	// such if it was not also scheduled (and so run) this tick
+	skipped := make(map[string]*taskrunner.TaskStatus)
+
+	for _, id := range ids {
+		d.mu.Lock()
+		after, ok := d.awaiting[id]
+		d.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		for _, task := range d.lookup.Dependents(after) {
+			// each dependent is run (or skipped) at most once per tick,
+			// however many of the tasks it depends upon have completed
+			if _, ok := runstatus[task]; ok {
+				continue
+			}
+
+			if _, ok := skipped[task]; ok {
+				continue
+			}
+
+			reason := stopped[id]
+			if reason != nil {
+				d.reset(task)
+				skipped[task] = &taskrunner.TaskStatus{
+					Ran:      false,
+					Error:    nil,
+					Warnings: []error{&UpstreamFailedError{Task: task, After: after, Reason: reason}},
+					Output:   nil,
+					Info:     &TriggeredInfo{After: after},
+				}
+				continue
+			}
+
+			if !d.satisfy(task, after) {
+				continue
+			}
+
+			suppressor.Suppress(task, fmt.Errorf("Skipping scheduled run of %s because it was triggered by %s this tick", task, after))
+
+			newstatus, err := runner.RunTask(task)
+			if err != nil {
+				// still awaited, so that its dependents are not lost
+				return nil, err
+			}
+
+			newstatus.Info = &TriggeredInfo{After: after}
+			runstatus[task] = newstatus
+		}
+
+		d.mu.Lock()
+		delete(d.awaiting, id)
+		d.mu.Unlock()
+	}
+
+	scheduledStatus, err := d.schedule.Tick(suppressor, at)
+	for task, newstatus := range scheduledStatus {
+		// don't overwrite status that we've already determined by triggering
+		if _, ok := runstatus[task]; ok {
+			continue
+		}
+
+		runstatus[task] = newstatus
+	}
+
+	for task, newstatus := range skipped {
+		if _, ok := runstatus[task]; !ok {
+			runstatus[task] = newstatus
+		}
+	}
+
+	return runstatus, err
+}
+
+// An ImmediateTracker considers every launched task to have succeeded as soon
+// as it is checked, eg: for -simulate
+type ImmediateTracker struct {
+	mu       sync.Mutex
+	launches int
+}
+
+func NewImmediateTracker() *ImmediateTracker {
+	return &ImmediateTracker{}
+}
+
+func (t *ImmediateTracker) Launched(status *taskrunner.TaskStatus) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// unique across restarts, in case the awaited tasks are restored
+	t.launches++
+	return []string{fmt.Sprintf("immediate-%d-%d", time.Now().UnixNano(), t.launches)}
+}
+
+func (t *ImmediateTracker) Stopped(ids []string) (map[string]error, error) {
+	stopped := make(map[string]error)
+	for _, id := range ids {
+		stopped[id] = nil
+	}
+
+	return stopped, nil
+}
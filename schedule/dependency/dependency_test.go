@@ -0,0 +1,253 @@
+package dependency
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule/crontab"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+func load(t *testing.T, lines ...string) *crontab.Crontab {
+	tab := crontab.NewCrontab()
+	if ok, err := tab.Load(strings.NewReader(strings.Join(lines, "\n"))); !ok {
+		t.Fatalf("Unexpected error loading crontab: %s", err)
+	}
+
+	return tab
+}
+
+// fakeTracker identifies each launch by task name, and reports those in
+// "stopped" as having stopped
+type fakeTracker struct {
+	stopped map[string]error
+	err     error
+}
+
+func (f *fakeTracker) Launched(status *taskrunner.TaskStatus) []string {
+	return []string{status.Output.(string)}
+}
+
+func (f *fakeTracker) Stopped(ids []string) (map[string]error, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	stopped := make(map[string]error)
+	for _, id := range ids {
+		if reason, ok := f.stopped[id]; ok {
+			stopped[id] = reason
+		}
+	}
+
+	return stopped, nil
+}
+
+func TestDependencySchedule(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	tab := load(t,
+		"4 15 * * * extract",
+		"load after=extract",
+		"report after=load")
+
+	launched := []string{}
+	ran := func(task string) (*taskrunner.TaskStatus, error) {
+		launched = append(launched, task)
+		return &taskrunner.TaskStatus{Ran: true, Output: task}, nil
+	}
+
+	t.Run("Dependents should run at the first tick after a task succeeds", func(t *testing.T) {
+		launched = []string{}
+		tracker := &fakeTracker{stopped: map[string]error{}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		runner := sched.Wrap(taskrunner.TaskRunnerFunc(ran))
+
+		if _, err := sched.Tick(runner, at); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if next := sched.Next(at); !next.Equal(at.Add(time.Minute)) {
+			t.Fatalf("Awaited task was not checked at the next minute: %v", next)
+		}
+
+		results, _ := sched.Tick(runner, at.Add(time.Minute))
+		if len(results) != 0 {
+			t.Fatalf("Dependent was run before its task stopped: %v", launched)
+		}
+
+		tracker.stopped["extract"] = nil
+		results, _ = sched.Tick(runner, at.Add(2*time.Minute))
+		if result, ok := results["load"]; !ok || !result.Ran {
+			t.Fatalf("Dependent was not run after its task succeeded")
+		}
+
+		if info, ok := results["load"].Info.(*TriggeredInfo); !ok || info.After != "extract" {
+			t.Fatalf("Dependent was not marked as triggered: %+v", results["load"].Info)
+		}
+
+		if awaiting := sched.Awaiting(); len(awaiting) != 1 || awaiting["load"] != "load" {
+			t.Fatalf("A triggered dependent was not itself awaited: %v", awaiting)
+		}
+
+		if strings.Join(launched, ",") != "extract,load" {
+			t.Fatalf("Unexpected tasks launched: %v", launched)
+		}
+	})
+
+	t.Run("Dependents should be skipped when a task fails", func(t *testing.T) {
+		tracker := &fakeTracker{stopped: map[string]error{"extract": errors.New("exited with code 1")}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		sched.Restore(map[string]string{"extract": "extract"})
+
+		launched = []string{}
+		results, _ := sched.Tick(sched.Wrap(taskrunner.TaskRunnerFunc(ran)), at.Add(time.Minute))
+		result := results["load"]
+		if result == nil || result.Ran || len(result.Warnings) != 1 || !suppression.IsDeliberate(result.Warnings[0]) {
+			t.Fatalf("Dependent was not skipped after its task failed: %+v", result)
+		}
+
+		if len(launched) != 0 || len(sched.Awaiting()) != 0 {
+			t.Fatalf("Dependent was run after its task failed: %v", launched)
+		}
+	})
+
+	t.Run("Errors checking tasks should be observed, and checked again", func(t *testing.T) {
+		tracker := &fakeTracker{err: errors.New("intentional error")}
+		var observed error
+		sched := NewDependencySchedule(tab, tab, tracker, func(err error) { observed = err })
+		sched.Restore(map[string]string{"extract": "extract"})
+
+		results, err := sched.Tick(sched.Wrap(taskrunner.TaskRunnerFunc(ran)), at.Add(time.Minute))
+		if err != nil || len(results) != 0 {
+			t.Fatalf("Failure to check was not limited to the check: %v %v", results, err)
+		}
+
+		if observed == nil {
+			t.Fatalf("Failure to check was not observed")
+		}
+
+		if len(sched.Awaiting()) != 1 {
+			t.Fatalf("Task was no longer awaited after a failure to check")
+		}
+	})
+
+	t.Run("Dependents of several tasks should run once all have succeeded", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * extract-a",
+			"4 15 * * * extract-b",
+			"report after=extract-a,extract-b")
+
+		launched = []string{}
+		tracker := &fakeTracker{stopped: map[string]error{"extract-a": nil}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		runner := sched.Wrap(taskrunner.TaskRunnerFunc(ran))
+		if _, err := sched.Tick(runner, at); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		results, _ := sched.Tick(runner, at.Add(time.Minute))
+		if _, ok := results["report"]; ok {
+			t.Fatalf("Dependent was run before all of its tasks succeeded")
+		}
+
+		if satisfied := sched.Satisfied(); strings.Join(satisfied["report"], ",") != "extract-a" {
+			t.Fatalf("Succeeded task was not recorded: %v", satisfied)
+		}
+
+		// as after a restart
+		restored := NewDependencySchedule(tab, tab, tracker, nil)
+		restored.Restore(sched.Awaiting())
+		restored.RestoreSatisfied(sched.Satisfied())
+
+		tracker.stopped["extract-b"] = nil
+		results, _ = restored.Tick(restored.Wrap(taskrunner.TaskRunnerFunc(ran)), at.Add(2*time.Minute))
+		if result, ok := results["report"]; !ok || !result.Ran {
+			t.Fatalf("Dependent was not run once all of its tasks succeeded")
+		}
+
+		if strings.Join(launched, ",") != "extract-a,extract-b,report" {
+			t.Fatalf("Dependent was not run exactly once: %v", launched)
+		}
+
+		if len(restored.Satisfied()) != 0 {
+			t.Fatalf("Succeeded tasks were not forgotten once the dependent ran")
+		}
+	})
+
+	t.Run("Dependents of several tasks should be skipped when any fails", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * extract-a",
+			"4 15 * * * extract-b",
+			"report after=extract-a,extract-b")
+
+		tracker := &fakeTracker{stopped: map[string]error{
+			"extract-a": nil,
+			"extract-b": errors.New("exited with code 1"),
+		}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		sched.Restore(map[string]string{"extract-a": "extract-a", "extract-b": "extract-b"})
+
+		launched = []string{}
+		results, _ := sched.Tick(sched.Wrap(taskrunner.TaskRunnerFunc(ran)), at.Add(time.Minute))
+		if result := results["report"]; result == nil || result.Ran || len(launched) != 0 {
+			t.Fatalf("Dependent was not skipped after one of its tasks failed: %+v", result)
+		}
+
+		if len(sched.Satisfied()) != 0 {
+			t.Fatalf("Succeeded tasks were remembered after the dependent was skipped")
+		}
+	})
+
+	t.Run("Dependents also due should be run when a task fails", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * extract",
+			"5 15 * * * load",
+			"load after=extract")
+
+		tracker := &fakeTracker{stopped: map[string]error{"extract": errors.New("exited with code 1")}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		sched.Restore(map[string]string{"extract": "extract"})
+
+		launched = []string{}
+		results, _ := sched.Tick(sched.Wrap(taskrunner.TaskRunnerFunc(ran)), at.Add(time.Minute))
+		if result := results["load"]; result == nil || !result.Ran || len(result.Warnings) != 0 {
+			t.Fatalf("Scheduled run was not reported after its upstream failed: %+v", result)
+		}
+
+		if strings.Join(launched, ",") != "load" {
+			t.Fatalf("Scheduled run was not made after its upstream failed: %v", launched)
+		}
+	})
+
+	t.Run("Tasks should still be awaited when a dependent fails to launch", func(t *testing.T) {
+		tracker := &fakeTracker{stopped: map[string]error{"extract": nil}}
+		sched := NewDependencySchedule(tab, tab, tracker, nil)
+		sched.Restore(map[string]string{"extract": "extract"})
+
+		failing := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return nil, errors.New("intentional error")
+		})
+
+		if _, err := sched.Tick(sched.Wrap(failing), at.Add(time.Minute)); err == nil {
+			t.Fatalf("Failure to launch was not returned")
+		}
+
+		if awaiting := sched.Awaiting(); awaiting["extract"] != "extract" {
+			t.Fatalf("Task was no longer awaited after its dependent failed to launch: %v", awaiting)
+		}
+	})
+
+	t.Run("Tasks without dependents should not be awaited", func(t *testing.T) {
+		sched := NewDependencySchedule(tab, tab, &fakeTracker{}, nil)
+		if _, err := sched.Wrap(taskrunner.TaskRunnerFunc(ran)).RunTask("report"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(sched.Awaiting()) != 0 {
+			t.Fatalf("Task without dependents was awaited")
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/wpalmer/ecscron/taskrunner"
@@ -12,12 +14,30 @@ import (
 type DumpEntry struct {
 	After time.Time
 	Tasks []string
+
+	// the tasks run after each task completes, following on from Tasks
+	Then map[string][]string
+}
+
+// A Chainer gives the tasks run after each task completes, eg: a Crontab
+type Chainer interface {
+	Dependents(task string) []string
 }
 
 func Dump(schedule Schedule, after time.Time, until time.Time) chan DumpEntry {
+	return DumpChained(schedule, nil, after, until)
+}
+
+// DumpChained is as Dump, additionally giving the chain of tasks to be run
+// after each scheduled task completes (if chainer is not nil)
+func DumpChained(schedule Schedule, chainer Chainer, after time.Time, until time.Time) chan DumpEntry {
 	var entry DumpEntry
+	var mu sync.Mutex
 	dump := make(chan DumpEntry)
 	dumpRunner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+		// the schedule may launch tasks concurrently
+		mu.Lock()
+		defer mu.Unlock()
 		entry.Tasks = append(entry.Tasks, task)
 		return &taskrunner.TaskStatus{}, nil
 	})
@@ -34,6 +54,10 @@ func Dump(schedule Schedule, after time.Time, until time.Time) chan DumpEntry {
 			entry = DumpEntry{After: next}
 			_, _ = schedule.Tick(dumpRunner, next)
 			if len(entry.Tasks) > 0 {
+				sort.Strings(entry.Tasks)
+				if chainer != nil {
+					entry.Then = chain(chainer, entry.Tasks)
+				}
 				dump <- entry
 			}
 
@@ -44,7 +68,40 @@ func Dump(schedule Schedule, after time.Time, until time.Time) chan DumpEntry {
 	return dump
 }
 
+// chain returns the dependents of each of the given tasks, their dependents,
+// and so on
+func chain(chainer Chainer, tasks []string) map[string][]string {
+	var then map[string][]string
+	pending := append([]string{}, tasks...)
+	for len(pending) > 0 {
+		task := pending[0]
+		pending = pending[1:]
+		if _, ok := then[task]; ok {
+			continue
+		}
+
+		dependents := chainer.Dependents(task)
+		if len(dependents) == 0 {
+			continue
+		}
+
+		if then == nil {
+			then = make(map[string][]string)
+		}
+		then[task] = dependents
+		pending = append(pending, dependents...)
+	}
+
+	return then
+}
+
 func DumpJson(writer io.Writer, schedule Schedule, after time.Time, until time.Time) (int, error) {
+	return DumpJsonChained(writer, schedule, nil, after, until)
+}
+
+// DumpJsonChained is as DumpJson, additionally giving the chain of tasks to
+// be run after each scheduled task completes (if chainer is not nil)
+func DumpJsonChained(writer io.Writer, schedule Schedule, chainer Chainer, after time.Time, until time.Time) (int, error) {
 	var written int
 	var writtenPart int
 	var err error
@@ -55,7 +112,7 @@ func DumpJson(writer io.Writer, schedule Schedule, after time.Time, until time.T
 		return written, err
 	}
 
-	channel := Dump(schedule, after, until)
+	channel := DumpChained(schedule, chainer, after, until)
 
 	glue := ""
 	for entry := range channel {
@@ -69,10 +126,20 @@ func DumpJson(writer io.Writer, schedule Schedule, after time.Time, until time.T
 			return written, err
 		}
 
-		writtenPart, err = fmt.Fprintf(writer, "%s{\"when\":%s,\"tasks\":%s}",
+		thenJson := []byte{}
+		if len(entry.Then) > 0 {
+			thenJson, err = json.Marshal(entry.Then)
+			if err != nil {
+				return written, err
+			}
+			thenJson = append([]byte(",\"then\":"), thenJson...)
+		}
+
+		writtenPart, err = fmt.Fprintf(writer, "%s{\"when\":%s,\"tasks\":%s%s}",
 			glue,
 			whenJson,
-			tasksJson)
+			tasksJson,
+			thenJson)
 		if err != nil {
 			return written, err
 		}
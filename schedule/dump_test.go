@@ -132,6 +132,32 @@ func TestBasicDump(t *testing.T) {
 		}
 	})
 
+	t.Run("Dump should not wait between the tasks of each tick", func(t *testing.T) {
+		// the spread of launches is applied only when running a tick (see
+		// SpreadTaskRunner), so a day of several tasks a minute is immediate
+		schedule := NewBasicSchedule()
+		for _, task := range []string{"testA", "testB", "testC"} {
+			schedule.Set(task, NextFunc(func(after time.Time) time.Time {
+				return after.Truncate(time.Minute).Add(time.Minute)
+			}))
+		}
+
+		testAfter := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		start := time.Now()
+		count := 0
+		for range Dump(schedule, testAfter, testAfter.Add(24*time.Hour)) {
+			count += 1
+		}
+
+		if count != 24*60 {
+			t.Fatalf("Dump returned %d ticks, rather than %d", count, 24*60)
+		}
+
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("Dump of a day took %v", elapsed)
+		}
+	})
+
 	t.Run("Empty Schedule should return a channel which immediately closes", func(t *testing.T) {
 		schedule := NewBasicSchedule()
 		testAfter := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
@@ -208,4 +234,40 @@ func TestJsonDump(t *testing.T) {
 			t.Fatalf("DumpJson reported inaccurate byte-count")
 		}
 	})
+	t.Run("Dependents should be shown as a chain", func(t *testing.T) {
+		schedule := NewBasicSchedule()
+		schedule.Set("Extract", NextFunc(func(after time.Time) time.Time {
+			if after.Before(time.Date(2006, 1, 2, 15, 3, 0, 0, time.UTC)) {
+				return time.Date(2006, 1, 2, 15, 3, 0, 0, time.UTC)
+			}
+
+			return time.Time{}
+		}))
+
+		chainer := dependents{"Extract": {"Load"}, "Load": {"Report"}}
+		testAfter := time.Date(2006, 1, 2, 15, 2, 0, 0, time.UTC)
+		testUntil := time.Date(2006, 1, 2, 15, 8, 0, 0, time.UTC)
+
+		buf := new(bytes.Buffer)
+		i, err := DumpJsonChained(buf, schedule, chainer, testAfter, testUntil)
+		if err != nil {
+			t.Fatalf("unexpected error while writing JSON: %v", err)
+		}
+
+		expected := "[{\"when\":\"2006-01-02 15:03:00\",\"tasks\":[\"Extract\"]," +
+			"\"then\":{\"Extract\":[\"Load\"],\"Load\":[\"Report\"]}}]"
+		if buf.String() != expected {
+			t.Fatalf("JSON did not match expected JSON: %s", buf.String())
+		}
+
+		if i != len(expected) {
+			t.Fatalf("DumpJson reported inaccurate byte-count")
+		}
+	})
+}
+
+type dependents map[string][]string
+
+func (d dependents) Dependents(task string) []string {
+	return d[task]
 }
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"time"
+)
+
+// The maximum number of events considered when searching for the next
+// jittered event, so that a very frequent Nexter cannot stall the scheduler.
+const maxJitterSearch = 1000
+
+// A JitterNexter delays each event of another Nexter by a pseudo-random
+// number of whole seconds, less than "Max". The delay is derived from the
+// Seed and the time of the original event, so is the same each time the
+// schedule is evaluated (including across restarts).
+type JitterNexter struct {
+	Nexter Nexter
+	Max    time.Duration
+	Seed   string
+}
+
+func NewJitterNexter(nexter Nexter, max time.Duration, seed string) *JitterNexter {
+	return &JitterNexter{Nexter: nexter, Max: max, Seed: seed}
+}
+
+// Offset returns the delay applied to the event at the given time
+func (j *JitterNexter) Offset(event time.Time) time.Duration {
+	seconds := uint64(j.Max / time.Second)
+	if seconds == 0 {
+		return time.Duration(0)
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(j.Seed))
+
+	var unix [8]byte
+	binary.BigEndian.PutUint64(unix[:], uint64(event.Unix()))
+	_, _ = hash.Write(unix[:])
+
+	return time.Duration(hash.Sum64()%seconds) * time.Second
+}
+
+func (j *JitterNexter) Next(after time.Time) time.Time {
+	var earliest time.Time
+
+	// any event from up to Max prior to "after" may have been delayed past it
+	cursor := after.Add(-j.Max)
+	for i := 0; i < maxJitterSearch; i++ {
+		event := j.Nexter.Next(cursor)
+
+		// events are only ever delayed, so nothing further can be earlier
+		if event.IsZero() || (!earliest.IsZero() && !event.Before(earliest)) {
+			break
+		}
+
+		delayed := event.Add(j.Offset(event))
+		if delayed.After(after) && (earliest.IsZero() || delayed.Before(earliest)) {
+			earliest = delayed
+		}
+
+		cursor = event
+	}
+
+	return earliest
+}
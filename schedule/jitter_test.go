@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterNexter(t *testing.T) {
+	everyMinute := NextFunc(func(after time.Time) time.Time {
+		return after.Truncate(time.Minute).Add(time.Minute)
+	})
+	start := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	t.Run("Offset should be stable and bounded", func(t *testing.T) {
+		jitter := NewJitterNexter(everyMinute, 30*time.Second, "test")
+
+		distinct := make(map[time.Duration]bool)
+		for i := 0; i < 60; i++ {
+			event := start.Add(time.Duration(i) * time.Minute)
+			offset := jitter.Offset(event)
+
+			if offset < 0 || offset >= 30*time.Second || offset%time.Second != 0 {
+				t.Fatalf("Offset was not a whole number of seconds within bounds: %v", offset)
+			}
+
+			if offset != NewJitterNexter(everyMinute, 30*time.Second, "test").Offset(event) {
+				t.Fatalf("Offset was not stable for the same seed and event")
+			}
+
+			distinct[offset] = true
+		}
+
+		if len(distinct) < 5 {
+			t.Fatalf("60 events resulted in only %d distinct offsets", len(distinct))
+		}
+	})
+
+	t.Run("Next should return each delayed event, in order", func(t *testing.T) {
+		jitter := NewJitterNexter(everyMinute, 90*time.Second, "test")
+
+		expected := []time.Time{}
+		for i := -1; i <= 30; i++ {
+			event := start.Add(time.Duration(i) * time.Minute)
+			expected = append(expected, event.Add(jitter.Offset(event)))
+		}
+
+		previous := start.Add(30 * time.Second)
+		for i := 0; i < 25; i++ {
+			next := jitter.Next(previous)
+			if !next.After(previous) {
+				t.Fatalf("Next was not after the given time: %v <= %v", next, previous)
+			}
+
+			found := false
+			for _, event := range expected {
+				if event.Equal(next) {
+					found = true
+				}
+
+				// nothing expected should have been skipped
+				if event.After(previous) && event.Before(next) {
+					t.Fatalf("Next skipped a delayed event: %v", event)
+				}
+			}
+
+			if !found {
+				t.Fatalf("Next returned an unexpected time: %v", next)
+			}
+
+			previous = next
+		}
+	})
+
+	t.Run("Zero Max should pass-through", func(t *testing.T) {
+		jitter := NewJitterNexter(everyMinute, time.Duration(0), "test")
+		if next := jitter.Next(start); !next.Equal(start.Add(time.Minute)) {
+			t.Fatalf("Next with zero Max did not pass-through: %v", next)
+		}
+	})
+}
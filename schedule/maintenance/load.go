@@ -0,0 +1,171 @@
+package maintenance
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorhill/cronexpr"
+	"github.com/wpalmer/ecscron/schedule"
+)
+
+var windowMatcher *regexp.Regexp
+var ignoredMatcher *regexp.Regexp
+var optionMatcher *regexp.Regexp
+
+var dateTimeFormats = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func init() {
+	ignoredMatcher = regexp.MustCompile("^\\s*(?:#.*)?$")
+	optionMatcher = regexp.MustCompile("^([-_A-Za-z0-9]+)=(.*)$")
+	windowMatcher = regexp.MustCompile("^\\s*" +
+		"(?:" +
+		"(\\d{4}-\\d{2}-\\d{2}(?:T[0-9:]+)?)\\s+" + // Absolute start
+		"(\\d{4}-\\d{2}-\\d{2}(?:T[0-9:]+)?)" + // Absolute end
+		"|" +
+		"(" +
+		"@\\S+" + // Predefined
+		"|" +
+		"[-0-9*/,]+\\s+" + // Minutes
+		"[-0-9*/,]+\\s+" + // Hours
+		"[-0-9*/,LW]+\\s+" + // Day of month
+		"[-0-9A-Za-z*/,]+\\s+" + // Month
+		"[-0-9A-Za-z*/,L#]+" + // Day of week
+		")\\s+" +
+		"([0-9][0-9a-z.]*)" + // Duration
+		")" +
+		"\\s+" +
+		"([^\\s=#]+)" + // Tasks / Tags
+		"((?:\\s+[-_A-Za-z0-9]+=[^\\s#]*)*)" + // Options
+		"(?:\\s+#.*)?" +
+		"\\s*$")
+}
+
+// Load reads maintenance windows, one per line, in either of the forms:
+//
+//	<cron expression> <duration> <tasks> [option=value ...]
+//	<start date/time> <end date/time> <tasks> [option=value ...]
+//
+// where <tasks> is a comma-separated list of task-name glob patterns and/or
+// "tag:<name>" references. Absolute dates are evaluated in the given location.
+func Load(r io.Reader, location *time.Location) ([]*Window, error) {
+	windows := []*Window{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ignoredMatcher.MatchString(line) {
+			continue
+		}
+
+		window, err := Parse(line, location)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse maintenance window '%s': %s", line, err)
+		}
+
+		windows = append(windows, window)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+func Parse(line string, location *time.Location) (*Window, error) {
+	matches := windowMatcher.FindStringSubmatch(line)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("Unknown maintenance window line format")
+	}
+
+	window := &Window{Name: strings.TrimSpace(line)}
+
+	if matches[1] != "" {
+		from, err := parseTime(matches[1], location)
+		if err != nil {
+			return nil, err
+		}
+
+		until, err := parseTime(matches[2], location)
+		if err != nil {
+			return nil, err
+		}
+
+		if !from.Before(until) {
+			return nil, fmt.Errorf("Window ends before it starts")
+		}
+
+		window.Start = schedule.NextTime(from)
+		window.Duration = until.Sub(from)
+	} else {
+		expr, err := cronexpr.Parse(matches[3])
+		if expr == nil {
+			return nil, fmt.Errorf("Failed to parse cron expression: %s", err)
+		}
+
+		duration, err := time.ParseDuration(matches[4])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse duration: %s", err)
+		}
+
+		if duration <= 0 {
+			return nil, fmt.Errorf("Duration must be positive")
+		}
+
+		window.Start = expr
+		window.Duration = duration
+	}
+
+	for _, match := range strings.Split(matches[5], ",") {
+		if strings.HasPrefix(match, "tag:") && len(match) > len("tag:") {
+			window.Tags = append(window.Tags, strings.TrimPrefix(match, "tag:"))
+			continue
+		}
+
+		if _, err := path.Match(match, ""); match == "" || err != nil {
+			return nil, fmt.Errorf("Invalid task pattern '%s'", match)
+		}
+
+		window.Tasks = append(window.Tasks, match)
+	}
+
+	for _, field := range strings.Fields(matches[6]) {
+		option := optionMatcher.FindStringSubmatch(field)
+
+		switch option[1] {
+		case "catchup":
+			switch option[2] {
+			case "true":
+				window.CatchUp = true
+			case "false":
+				window.CatchUp = false
+			default:
+				return nil, fmt.Errorf("Invalid catchup= value '%s'", option[2])
+			}
+		default:
+			return nil, fmt.Errorf("Unknown option '%s'", option[1])
+		}
+	}
+
+	return window, nil
+}
+
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	for _, format := range dateTimeFormats {
+		if t, err := time.ParseInLocation(format, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{},
+		fmt.Errorf("'%s' is not in YYYY-MM-DD[THH:mm[:ss]] format", value)
+}
@@ -0,0 +1,59 @@
+package maintenance
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Recurring and absolute windows should be loaded", func(t *testing.T) {
+		windows, err := Load(strings.NewReader(
+			"# weekly database maintenance\n"+
+				"0 2 * * 0 2h30m billing-*,tag:db catchup=true\n"+
+				"2006-01-02T18:00 2006-01-03 * # change freeze\n"), time.UTC)
+		if err != nil {
+			t.Fatalf("Loading valid windows failed: %s", err)
+		}
+
+		if len(windows) != 2 {
+			t.Fatalf("Did not load exactly 2 windows: %d", len(windows))
+		}
+
+		recurring := windows[0]
+		if recurring.Duration != 150*time.Minute || !recurring.CatchUp {
+			t.Fatalf("Recurring window was not loaded as expected: %+v", recurring)
+		}
+
+		if len(recurring.Tasks) != 1 || recurring.Tasks[0] != "billing-*" ||
+			len(recurring.Tags) != 1 || recurring.Tags[0] != "db" {
+			t.Fatalf("Recurring window tasks/tags were not loaded as expected: %+v", recurring)
+		}
+
+		if open, _ := recurring.Open(time.Date(2006, 1, 8, 4, 29, 0, 0, time.UTC)); !open {
+			t.Fatalf("Recurring window was not open during its second occurrence")
+		}
+
+		absolute := windows[1]
+		open, until := absolute.Open(time.Date(2006, 1, 2, 20, 0, 0, 0, time.UTC))
+		if !open || !until.Equal(time.Date(2006, 1, 3, 0, 0, 0, 0, time.UTC)) || absolute.CatchUp {
+			t.Fatalf("Absolute window was not loaded as expected: %+v", absolute)
+		}
+	})
+
+	t.Run("Invalid lines should fail", func(t *testing.T) {
+		for _, line := range []string{
+			"0 2 * * 0 billing",
+			"0 2 * * 0 2x billing",
+			"0 2 * * 0 -1h billing",
+			"2006-01-03 2006-01-02 billing",
+			"0 2 * * 0 1h billing catchup=maybe",
+			"0 2 * * 0 1h billing unknown=true",
+			"0 2 * * 0 1h [billing",
+		} {
+			if _, err := Load(strings.NewReader(line), time.UTC); err == nil {
+				t.Fatalf("Loading an invalid window succeeded: %s", line)
+			}
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package maintenance
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+// A Tagger looks up the names of tasks carrying a tag (eg: a Crontab)
+type Tagger interface {
+	Tagged(tag string) []string
+}
+
+// A Window is a (possibly recurring) period during which matching tasks
+// must not be run.
+type Window struct {
+	// A description of the window, for reporting
+	Name string
+
+	// The times at which the window opens
+	Start schedule.Nexter
+
+	// How long the window stays open, each time it opens
+	Duration time.Duration
+
+	// Glob patterns (as understood by path.Match) of tasks to suppress
+	Tasks []string
+
+	// Tags of tasks to suppress
+	Tags []string
+
+	// When true, any suppressed task is run once, as soon as the window closes
+	CatchUp bool
+}
+
+// Open reports whether the window is open at the given time, and if so, when
+// it will close.
+func (w *Window) Open(at time.Time) (bool, time.Time) {
+	start := w.Start.Next(at.Add(-w.Duration))
+	if start.IsZero() || start.After(at) {
+		return false, time.Time{}
+	}
+
+	return true, start.Add(w.Duration)
+}
+
+func (w *Window) matches(task string, tagger Tagger) bool {
+	for _, pattern := range w.Tasks {
+		if matched, _ := path.Match(pattern, task); matched {
+			return true
+		}
+	}
+
+	if tagger != nil {
+		for _, tag := range w.Tags {
+			for _, tagged := range tagger.Tagged(tag) {
+				if tagged == task {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// The Warning given for any task which is not run due to a maintenance window
+type SuppressedError struct {
+	Window *Window
+	Until  time.Time
+}
+
+func (e *SuppressedError) Error() string {
+	catchUp := "it will not be caught-up"
+	if e.Window.CatchUp {
+		catchUp = "it will be caught-up when the window closes"
+	}
+
+	return fmt.Sprintf("Skipping scheduled run during maintenance window '%s' (until %v), %s",
+		e.Window.Name, e.Until, catchUp)
+}
+
+func (e *SuppressedError) Deliberate() {}
+
+type CatchUpInfo struct {
+	Window *Window
+	Missed time.Time
+}
+
+type missedRun struct {
+	window *Window
+	missed time.Time
+	until  time.Time
+}
+
+// A MaintenanceSchedule wraps another Schedule, suppressing any tasks which
+// would run while a matching maintenance window is open.
+type MaintenanceSchedule struct {
+	schedule schedule.Schedule
+	windows  []*Window
+	tagger   Tagger
+	missed   map[string]*missedRun
+}
+
+func NewMaintenanceSchedule(schedule schedule.Schedule, windows []*Window, tagger Tagger) *MaintenanceSchedule {
+	return &MaintenanceSchedule{
+		schedule: schedule,
+		windows:  windows,
+		tagger:   tagger,
+		missed:   make(map[string]*missedRun),
+	}
+}
+
+func (m *MaintenanceSchedule) Next(from time.Time) time.Time {
+	next := m.schedule.Next(from)
+
+	for _, missed := range m.missed {
+		catchUp := missed.until
+		if !catchUp.After(from) {
+			// overdue, so catch up at the next whole-minute
+			catchUp = time.Date(from.Year(), from.Month(), from.Day(), from.Hour(),
+				from.Minute(), 0, 0, from.Location()).Add(time.Minute)
+		}
+
+		if next.IsZero() || catchUp.Before(next) {
+			next = catchUp
+		}
+	}
+
+	return next
+}
+
+// suppressedBy returns the first window which is open at the given time and
+// which matches the given task, along with the time it closes.
+func (m *MaintenanceSchedule) suppressedBy(task string, at time.Time) (*Window, time.Time) {
+	for _, window := range m.windows {
+		if open, until := window.Open(at); open && window.matches(task, m.tagger) {
+			return window, until
+		}
+	}
+
+	return nil, time.Time{}
+}
+
+func (m *MaintenanceSchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[string]*taskrunner.TaskStatus, error) {
+	// wrap the runner in a SuppressionTaskRunner, both to suppress anything
+	// matching an open window, and so that if we catch-up something which is
+	// also scheduled, we don't run it twice
+	suppressor := suppression.NewSuppressionTaskRunner(runner)
+
+	for _, window := range m.windows {
+		open, until := window.Open(at)
+		if !open {
+			continue
+		}
+
+		reason := &SuppressedError{Window: window, Until: until}
+		for _, pattern := range window.Tasks {
+			if err := suppressor.SuppressMatching(pattern, reason); err != nil {
+				return nil, fmt.Errorf("Invalid task pattern in maintenance window '%s': %s",
+					window.Name, err)
+			}
+		}
+
+		if m.tagger != nil {
+			for _, tag := range window.Tags {
+				for _, task := range m.tagger.Tagged(tag) {
+					suppressor.Suppress(task, reason)
+				}
+			}
+		}
+	}
+
+	runstatus := make(map[string]*taskrunner.TaskStatus)
+
+	for task, missed := range m.missed {
+		if missed.until.After(at) {
+			continue
+		}
+
+		// another window may have opened in the meantime
+		if window, until := m.suppressedBy(task, at); window != nil {
+			if window.CatchUp {
+				missed.until = until
+			} else {
+				delete(m.missed, task)
+			}
+			continue
+		}
+
+		delete(m.missed, task)
+		suppressor.Suppress(task, fmt.Errorf("Skipping scheduled run of %s because it was already caught-up this tick", task))
+
+		newstatus, err := runner.RunTask(task)
+		if err != nil {
+			return nil, err
+		}
+
+		newstatus.Info = &CatchUpInfo{Window: missed.window, Missed: missed.missed}
+		runstatus[task] = newstatus
+	}
+
+	scheduledStatus, err := m.schedule.Tick(suppressor, at)
+	for task, newstatus := range scheduledStatus {
+		// don't overwrite status that we've already determined by catching-up
+		if _, ok := runstatus[task]; ok {
+			continue
+		}
+
+		runstatus[task] = newstatus
+
+		for _, warning := range newstatus.Warnings {
+			suppressed, ok := warning.(*SuppressedError)
+			if !ok || !suppressed.Window.CatchUp {
+				continue
+			}
+
+			if _, ok := m.missed[task]; !ok {
+				m.missed[task] = &missedRun{
+					window: suppressed.Window,
+					missed: at,
+					until:  suppressed.Until,
+				}
+			}
+		}
+	}
+
+	return runstatus, err
+}
@@ -0,0 +1,168 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+type tagMap map[string][]string
+
+func (m tagMap) Tagged(tag string) []string {
+	return m[tag]
+}
+
+func everyMinute() schedule.Nexter {
+	return schedule.NextFunc(func(after time.Time) time.Time {
+		return after.Truncate(time.Minute).Add(time.Minute)
+	})
+}
+
+func TestWindow(t *testing.T) {
+	t.Run("Open should be true only within the window", func(t *testing.T) {
+		start := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC)
+		window := &Window{Start: schedule.NextTime(start), Duration: time.Hour}
+
+		if open, _ := window.Open(start.Add(time.Duration(-1))); open {
+			t.Fatalf("Window was open before it started")
+		}
+
+		open, until := window.Open(start)
+		if !open {
+			t.Fatalf("Window was not open at its start")
+		}
+
+		if !until.Equal(start.Add(time.Hour)) {
+			t.Fatalf("Window did not report its end: %v", until)
+		}
+
+		if open, _ := window.Open(start.Add(time.Hour)); open {
+			t.Fatalf("Window was still open at its (exclusive) end")
+		}
+	})
+}
+
+func TestMaintenanceSchedule(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	newSchedule := func(catchUp bool) *MaintenanceSchedule {
+		inner := schedule.NewBasicSchedule()
+		inner.Set("billing-a", everyMinute())
+		inner.Set("billing-b", everyMinute())
+		inner.Set("tagged", everyMinute())
+		inner.Set("other", everyMinute())
+
+		return NewMaintenanceSchedule(inner, []*Window{
+			&Window{
+				Name:     "test",
+				Start:    schedule.NextTime(start),
+				Duration: 30 * time.Minute,
+				Tasks:    []string{"billing-*"},
+				Tags:     []string{"db"},
+				CatchUp:  catchUp,
+			},
+		}, tagMap{"db": []string{"tagged"}})
+	}
+
+	t.Run("Tick should suppress matching tasks while open", func(t *testing.T) {
+		maintenance := newSchedule(false)
+
+		ran := make(map[string]bool)
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			ran[task] = true
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		results, err := maintenance.Tick(runner, start.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Tick gave an unexpected error: %s", err)
+		}
+
+		if len(ran) != 1 || !ran["other"] {
+			t.Fatalf("Only the non-matching task should have run: %v", ran)
+		}
+
+		for _, task := range []string{"billing-a", "billing-b", "tagged"} {
+			result := results[task]
+			if result == nil || result.Ran || len(result.Warnings) != 1 {
+				t.Fatalf("Suppressed task did not report a warning: %s", task)
+			}
+
+			if _, ok := result.Warnings[0].(*SuppressedError); !ok {
+				t.Fatalf("Suppressed task warning was not a SuppressedError: %s", task)
+			}
+		}
+
+		if next := maintenance.Next(start.Add(time.Minute)); !next.Equal(start.Add(2 * time.Minute)) {
+			t.Fatalf("Next should not have been affected without catch-up: %v", next)
+		}
+	})
+
+	t.Run("Tick should catch-up suppressed tasks once the window closes", func(t *testing.T) {
+		maintenance := newSchedule(true)
+
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		_, _ = maintenance.Tick(runner, start.Add(time.Minute))
+		_, _ = maintenance.Tick(runner, start.Add(2*time.Minute))
+
+		end := start.Add(30 * time.Minute)
+		runs := make(map[string]int)
+		countingRunner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			runs[task] += 1
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		results, err := maintenance.Tick(countingRunner, end)
+		if err != nil {
+			t.Fatalf("Tick gave an unexpected error: %s", err)
+		}
+
+		for _, task := range []string{"billing-a", "billing-b", "tagged", "other"} {
+			if runs[task] != 1 {
+				t.Fatalf("Task did not run exactly once when the window closed: %s (%d)", task, runs[task])
+			}
+		}
+
+		if _, ok := results["billing-a"].Info.(*CatchUpInfo); !ok {
+			t.Fatalf("Caught-up task did not include CatchUpInfo")
+		}
+
+		if info := results["billing-a"].Info.(*CatchUpInfo); !info.Missed.Equal(start.Add(time.Minute)) {
+			t.Fatalf("CatchUpInfo did not note the first missed run: %v", info.Missed)
+		}
+
+		_, _ = maintenance.Tick(countingRunner, end.Add(time.Minute))
+		if runs["billing-a"] != 2 {
+			t.Fatalf("Caught-up task was not back on its regular schedule")
+		}
+	})
+
+	t.Run("Next should include the end of the window when catching-up", func(t *testing.T) {
+		inner := schedule.NewBasicSchedule()
+		inner.Set("billing", schedule.NextTime(start.Add(time.Minute)))
+		maintenance := NewMaintenanceSchedule(inner, []*Window{
+			&Window{
+				Name:     "test",
+				Start:    schedule.NextTime(start),
+				Duration: 30 * time.Minute,
+				Tasks:    []string{"*"},
+				CatchUp:  true,
+			},
+		}, nil)
+
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+		_, _ = maintenance.Tick(runner, start.Add(time.Minute))
+
+		next := maintenance.Next(start.Add(time.Minute))
+		if !next.Equal(start.Add(30 * time.Minute)) {
+			t.Fatalf("Next did not return the end of the window: %v", next)
+		}
+	})
+}
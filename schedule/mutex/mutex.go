@@ -0,0 +1,273 @@
+package mutex
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+// A Lookup gives the mutual-exclusion groups of each task, eg: a Crontab
+type Lookup interface {
+	MutexGroups(task string) []string
+	MutexMembers(group string) []string
+	DefersOverlap(task string) bool
+}
+
+// A RunningFunc reports whether a task is currently running, eg: in ECS
+type RunningFunc func(task string) (bool, error)
+
+// The Warning given for any task which is not run because another member of
+// one of its groups is running
+type BusyError struct {
+	Task    string
+	Group   string
+	Running string
+
+	// when true, the task will be run once the group is clear
+	Deferred bool
+}
+
+func (e *BusyError) Error() string {
+	message := fmt.Sprintf("Skipping Task '%s', as '%s' in mutex group '%s' is running",
+		e.Task, e.Running, e.Group)
+	if e.Deferred {
+		message = fmt.Sprintf("%s, deferring until it is not", message)
+	}
+
+	return message
+}
+
+func (e *BusyError) Deliberate() {}
+
+type DeferredInfo struct {
+	// when the task was originally scheduled
+	Scheduled time.Time
+}
+
+// A MutexSchedule wraps another Schedule, preventing any task from running
+// while another task in the same mutual-exclusion group is running. Such
+// tasks are either skipped or, with overlap=defer, run at the first
+// whole-minute at which the group is clear.
+type MutexSchedule struct {
+	schedule schedule.Schedule
+	lookup   Lookup
+	running  RunningFunc
+	deferred map[string]time.Time
+}
+
+func NewMutexSchedule(schedule schedule.Schedule, lookup Lookup, running RunningFunc) *MutexSchedule {
+	return &MutexSchedule{
+		schedule: schedule,
+		lookup:   lookup,
+		running:  running,
+		deferred: make(map[string]time.Time),
+	}
+}
+
+// Deferred returns the tasks awaiting a clear group, and when each was
+// originally scheduled
+func (m *MutexSchedule) Deferred() map[string]time.Time {
+	deferred := make(map[string]time.Time)
+	for task, scheduled := range m.deferred {
+		deferred[task] = scheduled
+	}
+
+	return deferred
+}
+
+// Restore replaces the deferred tasks, eg: as returned by Deferred before a
+// restart
+func (m *MutexSchedule) Restore(deferred map[string]time.Time) {
+	m.deferred = make(map[string]time.Time)
+	for task, scheduled := range deferred {
+		m.deferred[task] = scheduled
+	}
+}
+
+func (m *MutexSchedule) Next(from time.Time) time.Time {
+	next := m.schedule.Next(from)
+	if len(m.deferred) == 0 {
+		return next
+	}
+
+	// check again at the next whole-minute
+	retry := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(),
+		from.Minute(), 0, 0, from.Location()).Add(time.Minute)
+	if next.IsZero() || retry.Before(next) {
+		next = retry
+	}
+
+	return next
+}
+
+// Wrap returns a TaskRunner which does not run any task while another member
+// of one of its groups is running. Members of a group are checked and
+// launched one at a time, so the TaskRunner is safe for concurrent use.
+func (m *MutexSchedule) Wrap(runner taskrunner.TaskRunner) taskrunner.TaskRunner {
+	return &mutexTaskRunner{
+		runner:   runner,
+		lookup:   m.lookup,
+		running:  m.running,
+		locks:    make(map[string]*sync.Mutex),
+		launched: make(map[string]bool),
+	}
+}
+
+type mutexTaskRunner struct {
+	runner  taskrunner.TaskRunner
+	lookup  Lookup
+	running RunningFunc
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+
+	// tasks launched by this runner, which may not yet be visible as running
+	launched map[string]bool
+}
+
+func (r *mutexTaskRunner) lock(group string) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, ok := r.locks[group]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.locks[group] = lock
+	}
+
+	return lock
+}
+
+// busy returns the first member of a group (other than the task itself)
+// which is running
+func (r *mutexTaskRunner) busy(task string, group string) (string, error) {
+	for _, member := range r.lookup.MutexMembers(group) {
+		if member == task {
+			continue
+		}
+
+		r.mu.Lock()
+		launched := r.launched[member]
+		r.mu.Unlock()
+		if launched {
+			return member, nil
+		}
+
+		running, err := r.running(member)
+		if err != nil {
+			return "", err
+		}
+
+		if running {
+			return member, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (r *mutexTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	// groups are sorted, so are always locked in the same order
+	groups := r.lookup.MutexGroups(task)
+	for _, group := range groups {
+		lock := r.lock(group)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	for _, group := range groups {
+		// a failed check fails only this task, as with any failure to launch
+		member, err := r.busy(task, group)
+		if err != nil {
+			return &taskrunner.TaskStatus{
+				Ran:      false,
+				Error:    fmt.Errorf("Failed to check mutex group '%s' of '%s': %w", group, task, err),
+				Warnings: []error{},
+				Output:   nil,
+			}, nil
+		}
+
+		if member != "" {
+			return &taskrunner.TaskStatus{
+				Ran:   false,
+				Error: nil,
+				Warnings: []error{&BusyError{Task: task, Group: group, Running: member,
+					Deferred: r.lookup.DefersOverlap(task)}},
+				Output: nil,
+			}, nil
+		}
+	}
+
+	status, err := r.runner.RunTask(task)
+	if err == nil && status.Ran && len(groups) > 0 {
+		r.mu.Lock()
+		r.launched[task] = true
+		r.mu.Unlock()
+	}
+
+	return status, err
+}
+
+// deferral returns the BusyError of a deferred task, if any
+func deferral(status *taskrunner.TaskStatus) *BusyError {
+	for _, warning := range status.Warnings {
+		if busy, ok := warning.(*BusyError); ok && busy.Deferred {
+			return busy
+		}
+	}
+
+	return nil
+}
+
+func (m *MutexSchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[string]*taskrunner.TaskStatus, error) {
+	wrapped := m.Wrap(runner)
+
+	// as with maintenance catch-ups, a deferred task which is also scheduled
+	// this tick is only run once
+	suppressor := suppression.NewSuppressionTaskRunner(wrapped)
+	runstatus := make(map[string]*taskrunner.TaskStatus)
+
+	tasks := make([]string, 0, len(m.deferred))
+	for task := range m.deferred {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	for _, task := range tasks {
+		scheduled := m.deferred[task]
+		delete(m.deferred, task)
+		suppressor.Suppress(task, fmt.Errorf("Skipping scheduled run of %s because its deferred run was made this tick", task))
+
+		newstatus, err := wrapped.RunTask(task)
+		if err != nil {
+			return nil, err
+		}
+
+		if deferral(newstatus) != nil {
+			m.deferred[task] = scheduled
+		}
+
+		newstatus.Info = &DeferredInfo{Scheduled: scheduled}
+		runstatus[task] = newstatus
+	}
+
+	scheduledStatus, err := m.schedule.Tick(suppressor, at)
+	for task, newstatus := range scheduledStatus {
+		// don't overwrite status that we've already determined by deferring
+		if _, ok := runstatus[task]; ok {
+			continue
+		}
+
+		runstatus[task] = newstatus
+		if _, ok := m.deferred[task]; !ok && deferral(newstatus) != nil {
+			m.deferred[task] = at
+		}
+	}
+
+	return runstatus, err
+}
@@ -0,0 +1,140 @@
+package mutex
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/schedule"
+	"github.com/wpalmer/ecscron/schedule/crontab"
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+func load(t *testing.T, lines ...string) *crontab.Crontab {
+	tab := crontab.NewCrontab()
+	if ok, err := tab.Load(strings.NewReader(strings.Join(lines, "\n"))); !ok {
+		t.Fatalf("Unexpected error loading crontab: %s", err)
+	}
+
+	return tab
+}
+
+func TestMutexSchedule(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	ran := func(task string) (*taskrunner.TaskStatus, error) {
+		return &taskrunner.TaskStatus{Ran: true}, nil
+	}
+
+	t.Run("A task should be skipped while another member of its group is running", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * rebuild-a mutex=index",
+			"0 0 1 1 * rebuild-b mutex=index",
+			"4 15 * * * other")
+		running := func(task string) (bool, error) { return task == "rebuild-b", nil }
+
+		sched := NewMutexSchedule(tab, tab, running)
+		results, err := sched.Tick(taskrunner.TaskRunnerFunc(ran), at)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		result := results["rebuild-a"]
+		if result.Ran || len(result.Warnings) != 1 || !suppression.IsDeliberate(result.Warnings[0]) {
+			t.Fatalf("Task was not skipped while its group was busy: %+v", result)
+		}
+
+		if !results["other"].Ran {
+			t.Fatalf("Task outside of the group was not run")
+		}
+
+		if len(sched.Deferred()) != 0 {
+			t.Fatalf("Skipped task was deferred")
+		}
+	})
+
+	t.Run("Members launched in the same tick should exclude each other", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * rebuild-a mutex=index priority=1",
+			"4 15 * * * rebuild-b mutex=index")
+		tab.SetConcurrency(2)
+		running := func(task string) (bool, error) { return false, nil }
+
+		var mu sync.Mutex
+		launched := []string{}
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			launched = append(launched, task)
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		_, _ = NewMutexSchedule(tab, tab, running).Tick(runner, at)
+		if len(launched) != 1 {
+			t.Fatalf("More than one member of a group was launched at once: %v", launched)
+		}
+	})
+
+	t.Run("overlap=defer should run the task once the group is clear", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * rebuild-a mutex=index overlap=defer",
+			"0 0 1 1 * rebuild-b mutex=index")
+		busy := true
+		running := func(task string) (bool, error) { return busy && task == "rebuild-b", nil }
+
+		sched := NewMutexSchedule(tab, tab, running)
+		results, _ := sched.Tick(taskrunner.TaskRunnerFunc(ran), at)
+		if results["rebuild-a"].Ran {
+			t.Fatalf("Task was run while its group was busy")
+		}
+
+		next := sched.Next(at)
+		if !next.Equal(at.Add(time.Minute)) {
+			t.Fatalf("Deferred task was not checked again at the next minute: %v", next)
+		}
+
+		results, _ = sched.Tick(taskrunner.TaskRunnerFunc(ran), next)
+		if results["rebuild-a"].Ran || len(sched.Deferred()) != 1 {
+			t.Fatalf("Deferred task was not deferred again while its group was busy")
+		}
+
+		busy = false
+		next = sched.Next(next)
+		results, _ = sched.Tick(taskrunner.TaskRunnerFunc(ran), next)
+		info, ok := results["rebuild-a"].Info.(*DeferredInfo)
+		if !results["rebuild-a"].Ran || !ok || !info.Scheduled.Equal(at) {
+			t.Fatalf("Deferred task was not run once its group was clear: %+v", results["rebuild-a"])
+		}
+
+		if len(sched.Deferred()) != 0 {
+			t.Fatalf("Task remained deferred after running")
+		}
+	})
+
+	t.Run("A failed check should fail only that task", func(t *testing.T) {
+		tab := load(t,
+			"4 15 * * * rebuild-a mutex=index",
+			"4 15 * * * rebuild-b mutex=index")
+		running := func(task string) (bool, error) { return false, errors.New("intentional") }
+
+		results, err := NewMutexSchedule(tab, tab, running).Tick(taskrunner.TaskRunnerFunc(ran), at)
+		if err != nil || results["rebuild-a"].Ran || results["rebuild-a"].Error == nil {
+			t.Fatalf("Failed check was not reported as a task error: %+v, %v", results["rebuild-a"], err)
+		}
+	})
+
+	t.Run("Tasks without a group should not be checked", func(t *testing.T) {
+		var sched schedule.Schedule = load(t, "4 15 * * * other")
+		running := func(task string) (bool, error) {
+			t.Fatalf("Checked whether '%s' was running", task)
+			return false, nil
+		}
+
+		results, _ := NewMutexSchedule(sched, load(t), running).Tick(taskrunner.TaskRunnerFunc(ran), at)
+		if !results["other"].Ran {
+			t.Fatalf("Task without a group was not run")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+// RunTasks passes each of the named tasks to the TaskRunner, in order,
+// collecting the results. With a concurrency above 1, up to that many tasks
+// are run at once (so the TaskRunner must be safe for concurrent use);
+// otherwise each task is run only once the previous has finished. In the event
+// of an error, no further tasks are started, and the results so far are
+// returned along with the first error.
+func RunTasks(runner taskrunner.TaskRunner, tasks []string, concurrency int) (map[string]*taskrunner.TaskStatus, error) {
+	results := make(map[string]*taskrunner.TaskStatus)
+
+	if concurrency <= 1 {
+		for _, task := range tasks {
+			result, err := runner.RunTask(task)
+			if err != nil {
+				return results, err
+			}
+
+			results[task] = result
+		}
+
+		return results, nil
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	slots := make(chan struct{}, concurrency)
+
+	for _, task := range tasks {
+		slots <- struct{}{}
+
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(task string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := runner.RunTask(task)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			results[task] = result
+		}(task)
+	}
+
+	wg.Wait()
+	return results, firstErr
+}
+
+// A SpreadTaskRunner starts each of the tasks due in a tick evenly across a
+// window, rather than all at once, by delaying each until its share of the
+// window has passed. Any other task (eg: a retry, or a dependent) is run at
+// once. As it sleeps, it is only for use when actually running a tick, not
+// when planning (eg: Dump).
+type SpreadTaskRunner struct {
+	runner taskrunner.TaskRunner
+	start  time.Time
+	delays map[string]time.Duration
+}
+
+// NewSpreadTaskRunner creates a SpreadTaskRunner, to start the given tasks,
+// in order, across the spread, beginning now
+func NewSpreadTaskRunner(runner taskrunner.TaskRunner, tasks []string, spread time.Duration) *SpreadTaskRunner {
+	delays := make(map[string]time.Duration)
+	for i, task := range tasks {
+		delays[task] = spread * time.Duration(i) / time.Duration(len(tasks))
+	}
+
+	return &SpreadTaskRunner{runner: runner, start: time.Now(), delays: delays}
+}
+
+func (r *SpreadTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	if delay, ok := r.delays[task]; ok {
+		if wait := time.Until(r.start.Add(delay)); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+
+	return r.runner.RunTask(task)
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func TestRunTasks(t *testing.T) {
+	tasks := make([]string, 20)
+	for i := range tasks {
+		tasks[i] = fmt.Sprintf("task-%d", i)
+	}
+
+	t.Run("Should run no more than the concurrency limit at once", func(t *testing.T) {
+		var mu sync.Mutex
+		running, peak := 0, 0
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			mu.Lock()
+			running += 1
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running -= 1
+			mu.Unlock()
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		results, err := RunTasks(runner, tasks, 4)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(results) != len(tasks) {
+			t.Fatalf("Only %d of %d results were collected", len(results), len(tasks))
+		}
+
+		if peak < 2 || peak > 4 {
+			t.Fatalf("%d tasks ran at once, with a limit of 4", peak)
+		}
+	})
+
+	t.Run("Should run serially by default", func(t *testing.T) {
+		order := []string{}
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			order = append(order, task)
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		if _, err := RunTasks(runner, tasks, 0); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		for i, task := range order {
+			if task != tasks[i] {
+				t.Fatalf("Tasks were not run in order: %v", order)
+			}
+		}
+	})
+
+	t.Run("Should stop starting tasks after an error", func(t *testing.T) {
+		var mu sync.Mutex
+		runs := 0
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			mu.Lock()
+			runs += 1
+			mu.Unlock()
+			return nil, errors.New("intentional")
+		})
+
+		if _, err := RunTasks(runner, tasks, 2); err == nil {
+			t.Fatalf("Always-failing runner did not give an error")
+		}
+
+		if runs >= len(tasks) {
+			t.Fatalf("Every task was started, despite errors")
+		}
+	})
+}
+
+func TestSpreadTaskRunner(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("Tasks should be started evenly across the spread, with concurrency %d", concurrency), func(t *testing.T) {
+			var mu sync.Mutex
+			started := make(map[string]time.Duration)
+			start := time.Now()
+			runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				started[task] = time.Since(start)
+				return &taskrunner.TaskStatus{Ran: true}, nil
+			})
+
+			tasks := []string{"first", "second", "third", "fourth"}
+			spread := NewSpreadTaskRunner(runner, tasks, 200*time.Millisecond)
+			if _, err := RunTasks(spread, tasks, concurrency); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			for i, task := range tasks {
+				expected := time.Duration(i) * 50 * time.Millisecond
+				if started[task] < expected || started[task] > expected+40*time.Millisecond {
+					t.Fatalf("Task '%s' started after %v, rather than %v", task, started[task], expected)
+				}
+			}
+		})
+	}
+
+	t.Run("Other tasks should be run at once", func(t *testing.T) {
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		spread := NewSpreadTaskRunner(runner, []string{"first", "second"}, time.Hour)
+		start := time.Now()
+		if _, err := spread.RunTask("retried"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+			t.Fatalf("Task which was not due was delayed by %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package schedule
+
+import (
+	"sort"
+)
+
+// A Prioritizer gives the priority of each task. Within a tick, tasks with a
+// higher priority are launched first.
+type Prioritizer interface {
+	Priority(task string) int
+}
+
+// SortByPriority sorts tasks by descending priority, then by name
+func SortByPriority(tasks []string, prioritizer Prioritizer) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		pi, pj := prioritizer.Priority(tasks[i]), prioritizer.Priority(tasks[j])
+		if pi != pj {
+			return pi > pj
+		}
+
+		return tasks[i] < tasks[j]
+	})
+}
@@ -2,6 +2,8 @@ package retry
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/wpalmer/ecscron/schedule"
@@ -14,16 +16,25 @@ type retryTaskStatus struct {
 	ok       bool
 }
 
+// A RetrySchedule wraps another Schedule, running any failed task again at
+// the next whole-minute. It is safe for concurrent use, eg: reporting Pending
+// retries while a Tick is in progress.
 type RetrySchedule struct {
-	schedule   schedule.Schedule
-	maxRetries int64
-	tasks      map[string]*retryTaskStatus
+	mu          sync.Mutex
+	schedule    schedule.Schedule
+	maxRetries  int64
+	concurrency int
+	tasks       map[string]*retryTaskStatus
 }
 
 type RetryInfo struct {
 	Attempt    int64
 	MaxRetries int64
 	Output     interface{}
+
+	// Exhausted is true when this attempt failed, and there are no attempts
+	// remaining
+	Exhausted bool
 }
 
 func NewRetrySchedule(schedule schedule.Schedule, numRetries int64) *RetrySchedule {
@@ -34,10 +45,77 @@ func NewRetrySchedule(schedule schedule.Schedule, numRetries int64) *RetrySchedu
 	}
 }
 
+// suppressed returns true if a task was deliberately not run (eg: paused),
+// which should not be considered a failure
+func suppressed(status *taskrunner.TaskStatus) bool {
+	for _, warning := range status.Warnings {
+		if suppression.IsDeliberate(warning) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetConcurrency sets how many retries may be launched at once within a tick
+// (see schedule.RunTasks). By default, retries are launched one after another.
+func (r *RetrySchedule) SetConcurrency(concurrency int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.concurrency = concurrency
+}
+
+// needsRetry returns true if a task failed, and has attempts remaining.
+// maxRetries is the total number of attempts, including the first (scheduled)
+// run, so that a failing task is run exactly maxRetries times. Next and Tick
+// must agree on this, or Next wakes for a retry which Tick does not make.
+func (r *RetrySchedule) needsRetry(status *retryTaskStatus) bool {
+	if status.ok {
+		return false
+	}
+
+	return r.maxRetries < int64(0) || status.attempts < r.maxRetries
+}
+
+// Pending returns the number of attempts made so far, for each task which is
+// awaiting a retry
+func (r *RetrySchedule) Pending() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := make(map[string]int64)
+	for task, status := range r.tasks {
+		if r.needsRetry(status) {
+			pending[task] = status.attempts
+		}
+	}
+
+	return pending
+}
+
+// Restore marks each task as awaiting a retry, having made the given number of
+// attempts so far, eg: as returned by Pending before a restart
+func (r *RetrySchedule) Restore(pending map[string]int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for task, attempts := range pending {
+		r.tasks[task] = &retryTaskStatus{attempts: attempts, ok: false}
+	}
+}
+
+func (r *RetrySchedule) MaxRetries() int64 {
+	return r.maxRetries
+}
+
 func (r *RetrySchedule) Next(from time.Time) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// If any tasks need a retry, schedule them for the next whole-minute
 	for _, status := range r.tasks {
-		if !status.ok && r.maxRetries < int64(0) || status.attempts <= r.maxRetries {
+		if r.needsRetry(status) {
 			return time.Date(from.Year(), from.Month(), from.Day(), from.Hour(),
 				from.Minute(), 0, 0, from.Location()).Add(time.Minute)
 		}
@@ -50,35 +128,62 @@ func (r *RetrySchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[st
 	// wrap the runner in a SuppressionTaskRunner so, if we retry something that is also schedued, we don't run it twice
 	suppressor := suppression.NewSuppressionTaskRunner(runner)
 
-	runstatus := make(map[string]*taskrunner.TaskStatus)
-
+	r.mu.Lock()
+	retries := []string{}
 	for task, status := range r.tasks {
-		if !status.ok && r.maxRetries < 0 || status.attempts < r.maxRetries {
+		if r.needsRetry(status) {
 			suppressor.Suppress(task, fmt.Errorf("Skipping scheduled run of %s because it was already retried this tick", task))
-			r.tasks[task].attempts += 1
+			status.attempts += 1
+			retries = append(retries, task)
+		}
+	}
+	if prioritizer, ok := r.schedule.(schedule.Prioritizer); ok {
+		schedule.SortByPriority(retries, prioritizer)
+	} else {
+		sort.Strings(retries)
+	}
+	concurrency := r.concurrency
+	r.mu.Unlock()
 
-			newstatus, err := runner.RunTask(task)
-			newstatus.Info = &RetryInfo{
-				Attempt:    r.tasks[task].attempts,
-				MaxRetries: r.maxRetries,
-			}
+	// the lock is not held while running tasks, which may take some time
+	runstatus, err := schedule.RunTasks(runner, retries, concurrency)
+	if err != nil {
+		return nil, err
+	}
 
-			if err != nil {
-				return nil, err
-			}
+	r.mu.Lock()
+	for task, newstatus := range runstatus {
+		status := r.tasks[task]
+		attempt := status.attempts
+		if newstatus.Throttled {
+			// a throttled attempt does not count towards the limit
+			status.attempts -= 1
+		}
 
-			runstatus[task] = newstatus
-			r.tasks[task].ok = newstatus.Ran
+		status.ok = newstatus.Ran || suppressed(newstatus)
+		newstatus.Info = &RetryInfo{
+			Attempt:    attempt,
+			MaxRetries: r.maxRetries,
+			Exhausted:  !status.ok && !r.needsRetry(status),
 		}
 	}
+	r.mu.Unlock()
 
 	scheduledStatus, err := r.schedule.Tick(suppressor, at)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for task, newstatus := range scheduledStatus {
 		// don't overwrite status that we've already determined by retrying
 		if _, ok := runstatus[task]; !ok {
+			attempts := int64(1)
+			if newstatus.Throttled {
+				attempts = 0
+			}
+
 			r.tasks[task] = &retryTaskStatus{
-				attempts: 1,
-				ok:       newstatus.Ran || newstatus.Running,
+				attempts: attempts,
+				ok:       newstatus.Ran || newstatus.Running || suppressed(newstatus),
 			}
 			runstatus[task] = newstatus
 		}
@@ -2,6 +2,8 @@ package retry
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,6 +11,11 @@ import (
 	"github.com/wpalmer/ecscron/taskrunner"
 )
 
+type deliberateError struct{}
+
+func (e *deliberateError) Error() string { return "deliberate" }
+func (e *deliberateError) Deliberate()   {}
+
 func TestRetrySchedule(t *testing.T) {
 	t.Run("Next should initially pass to inner schedule", func(t *testing.T) {
 		innerSchedule := schedule.NewBasicSchedule()
@@ -146,6 +153,25 @@ func TestRetrySchedule(t *testing.T) {
 		}
 	})
 
+	t.Run("Deliberate suppression should not result in retry", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+
+		suppressedRunner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Warnings: []error{&deliberateError{}}}, nil
+		})
+
+		testAfter := time.Date(2006, 1, 2, 15, 4, 30, 0, time.UTC)
+		testNext := testAfter.Add((time.Second * 30) + (time.Minute * 2))
+		innerSchedule.Set("test", schedule.NextTime(testNext))
+
+		outerSchedule := NewRetrySchedule(innerSchedule, -1)
+		_, _ = outerSchedule.Tick(suppressedRunner, testNext)
+
+		if pending := outerSchedule.Pending(); len(pending) != 0 {
+			t.Fatalf("Deliberately suppressed task was scheduled for retry: %v", pending)
+		}
+	})
+
 	t.Run("Failure should retry only maxRetries times", func(t *testing.T) {
 		innerSchedule := schedule.NewBasicSchedule()
 
@@ -177,6 +203,67 @@ func TestRetrySchedule(t *testing.T) {
 		}
 	})
 
+	t.Run("Failure should be attempted exactly maxRetries times in total", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		innerSchedule.Set("test", schedule.NextTime(testAt))
+
+		runs := 0
+		failRunner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			runs += 1
+			return &taskrunner.TaskStatus{Ran: false}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 3)
+		_, _ = outerSchedule.Tick(failRunner, testAt)
+
+		attempts := []int64{}
+		for at := testAt; ; {
+			next := outerSchedule.Next(at)
+			if next.IsZero() {
+				break
+			}
+
+			if len(attempts) > 5 {
+				t.Fatalf("Next kept scheduling retries: %v", attempts)
+			}
+
+			results, _ := outerSchedule.Tick(failRunner, next)
+			if info, ok := results["test"].Info.(*RetryInfo); ok {
+				attempts = append(attempts, info.Attempt)
+			}
+			at = next
+		}
+
+		if runs != 3 || len(attempts) != 2 || attempts[0] != 2 || attempts[1] != 3 {
+			t.Fatalf("Always-failing task was not attempted exactly 3 times: %d runs, retries %v", runs, attempts)
+		}
+	})
+
+	t.Run("Success should not be run again while attempts remain", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		innerSchedule.Set("test", schedule.NextTime(testAt))
+
+		runs := 0
+		successRunner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			runs += 1
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 3)
+		_, _ = outerSchedule.Tick(successRunner, testAt)
+
+		if next := outerSchedule.Next(testAt); !next.IsZero() {
+			t.Fatalf("Next scheduled a retry of a successful task: %v", next)
+		}
+
+		_, _ = outerSchedule.Tick(successRunner, testAt.Add(time.Minute))
+		if runs != 1 {
+			t.Fatalf("Successful task was retried: %d runs", runs)
+		}
+	})
+
 	t.Run("Actual errors should not pass-through", func(t *testing.T) {
 		innerSchedule := schedule.NewBasicSchedule()
 
@@ -211,3 +298,125 @@ func TestRetrySchedule(t *testing.T) {
 		}
 	})
 }
+
+func TestRetryPending(t *testing.T) {
+	t.Run("Pending should list only failed tasks with attempts remaining", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		innerSchedule.Set("fail", schedule.NextTime(testAt))
+		innerSchedule.Set("succeed", schedule.NextTime(testAt))
+
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: task == "succeed"}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 2)
+		_, _ = outerSchedule.Tick(runner, testAt)
+
+		pending := outerSchedule.Pending()
+		if len(pending) != 1 || pending["fail"] != 1 {
+			t.Fatalf("Pending did not list exactly the failed task: %v", pending)
+		}
+
+		results, _ := outerSchedule.Tick(runner, testAt.Add(time.Minute))
+		if info, ok := results["fail"].Info.(*RetryInfo); !ok || !info.Exhausted {
+			t.Fatalf("Final failed attempt was not marked as exhausted: %+v", results["fail"].Info)
+		}
+
+		if pending := outerSchedule.Pending(); len(pending) != 0 {
+			t.Fatalf("Pending listed a task with no attempts remaining: %v", pending)
+		}
+
+		if next := outerSchedule.Next(testAt.Add(time.Minute)); !next.IsZero() {
+			t.Fatalf("Next scheduled a retry with no attempts remaining: %v", next)
+		}
+	})
+	t.Run("Restore should resume pending retries", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+
+		var passedTasks []string
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			passedTasks = append(passedTasks, task)
+			return &taskrunner.TaskStatus{Ran: false}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 3)
+		outerSchedule.Restore(map[string]int64{"test": 2})
+
+		if pending := outerSchedule.Pending(); pending["test"] != 2 {
+			t.Fatalf("Restored retry was not pending: %v", pending)
+		}
+
+		results, _ := outerSchedule.Tick(runner, outerSchedule.Next(testAt))
+		info, ok := results["test"].Info.(*RetryInfo)
+		if len(passedTasks) != 1 || !ok || info.Attempt != 3 || !info.Exhausted {
+			t.Fatalf("Restored retry was not attempted as expected: %v %+v", passedTasks, results["test"].Info)
+		}
+	})
+	t.Run("Concurrent retries should each be attempted once per tick", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		innerSchedule.SetConcurrency(4)
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		for i := 0; i < 20; i++ {
+			innerSchedule.Set(fmt.Sprintf("test-%d", i), schedule.NextTime(testAt))
+		}
+
+		var mu sync.Mutex
+		runs := make(map[string]int)
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[task] += 1
+			return &taskrunner.TaskStatus{Ran: false}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 3)
+		outerSchedule.SetConcurrency(4)
+
+		next := testAt.Add(-time.Second)
+		for i := 0; i < 3; i++ {
+			next = outerSchedule.Next(next)
+			_, _ = outerSchedule.Tick(runner, next)
+		}
+
+		for i := 0; i < 20; i++ {
+			if task := fmt.Sprintf("test-%d", i); runs[task] != 3 {
+				t.Fatalf("Task '%s' was attempted %d times, rather than 3", task, runs[task])
+			}
+		}
+
+		if pending := outerSchedule.Pending(); len(pending) != 0 {
+			t.Fatalf("Retries remained pending after all attempts: %v", pending)
+		}
+	})
+	t.Run("Throttled attempts should not count towards the limit", func(t *testing.T) {
+		innerSchedule := schedule.NewBasicSchedule()
+		testAt := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		innerSchedule.Set("test", schedule.NextTime(testAt))
+
+		throttled := 3
+		runs := 0
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			runs += 1
+			if runs <= throttled {
+				return &taskrunner.TaskStatus{Throttled: true}, nil
+			}
+			return &taskrunner.TaskStatus{Ran: false}, nil
+		})
+
+		outerSchedule := NewRetrySchedule(innerSchedule, 2)
+		next := testAt.Add(-time.Second)
+		for i := 0; i < 6; i++ {
+			next = outerSchedule.Next(next)
+			if next.IsZero() {
+				break
+			}
+			_, _ = outerSchedule.Tick(runner, next)
+		}
+
+		if runs != throttled+2 {
+			t.Fatalf("Task was run %d times, rather than %d throttled and 2 failed attempts", runs, throttled)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"sort"
 	"time"
 
 	"github.com/wpalmer/ecscron/taskrunner"
@@ -76,17 +77,52 @@ type Schedule interface {
 }
 
 type BasicSchedule struct {
-	table map[string]Nexter
+	table       map[string]Nexter
+	priorities  map[string]int
+	concurrency int
 }
 
 func NewBasicSchedule() *BasicSchedule {
-	return &BasicSchedule{make(map[string]Nexter)}
+	return &BasicSchedule{table: make(map[string]Nexter), priorities: make(map[string]int)}
+}
+
+// SetPriority sets the priority of the named task (0 by default). Within a
+// tick, tasks are launched in descending order of priority, then by name.
+func (s *BasicSchedule) SetPriority(name string, priority int) {
+	s.priorities[name] = priority
+}
+
+func (s *BasicSchedule) Priority(name string) int {
+	return s.priorities[name]
+}
+
+// SetConcurrency sets how many tasks may be launched at once within a tick
+// (see RunTasks). By default, tasks are launched one after another.
+func (s *BasicSchedule) SetConcurrency(concurrency int) {
+	s.concurrency = concurrency
 }
 
 func (s *BasicSchedule) Set(name string, nexter Nexter) {
 	s.table[name] = nexter
 }
 
+// Nexter returns the Nexter for the named task
+func (s *BasicSchedule) Nexter(name string) (Nexter, bool) {
+	nexter, ok := s.table[name]
+	return nexter, ok
+}
+
+// Tasks returns the (sorted) names of all tasks in the schedule
+func (s *BasicSchedule) Tasks() []string {
+	tasks := make([]string, 0, len(s.table))
+	for name := range s.table {
+		tasks = append(tasks, name)
+	}
+	sort.Strings(tasks)
+
+	return tasks
+}
+
 func (s *BasicSchedule) Next(after time.Time) time.Time {
 	var earliest time.Time
 
@@ -106,21 +142,28 @@ func (s *BasicSchedule) Next(after time.Time) time.Time {
 }
 
 func (s *BasicSchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[string]*taskrunner.TaskStatus, error) {
-	results := make(map[string]*taskrunner.TaskStatus)
-	after := at.Add(time.Duration(-1))
+	due := Due(s, at)
+	SortByPriority(due, s)
+	return RunTasks(runner, due, s.concurrency)
+}
 
-	for name, entry := range s.table {
-		next := entry.Next(after)
-		if !next.IsZero() && next.Equal(at) {
-			result, err := runner.RunTask(name)
+// Due returns the (sorted) names of the tasks in the lookup which are due at
+// exactly the given time
+func Due(lookup TaskLookup, at time.Time) []string {
+	due := []string{}
+	after := at.Add(time.Duration(-1))
 
-			if err != nil {
-				return results, err
-			}
+	for _, task := range lookup.Tasks() {
+		nexter, ok := lookup.Nexter(task)
+		if !ok {
+			continue
+		}
 
-			results[name] = result
+		next := nexter.Next(after)
+		if !next.IsZero() && next.Equal(at) {
+			due = append(due, task)
 		}
 	}
 
-	return results, nil
+	return due
 }
@@ -186,6 +186,32 @@ func TestBasicSchedule(t *testing.T) {
 		}
 	})
 
+	t.Run("Tasks should list the names of tasks which have been Set", func(t *testing.T) {
+		schedule := NewBasicSchedule()
+		schedule.Set("testB", NextTime(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)))
+		schedule.Set("testA", NextTime(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)))
+
+		tasks := schedule.Tasks()
+		if len(tasks) != 2 || tasks[0] != "testA" || tasks[1] != "testB" {
+			t.Fatalf("Tasks did not return the sorted task names: %v", tasks)
+		}
+	})
+
+	t.Run("Nexter should return the Nexter of a task which has been Set", func(t *testing.T) {
+		schedule := NewBasicSchedule()
+		testAdd := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+		schedule.Set("test", NextTime(testAdd))
+
+		nexter, ok := schedule.Nexter("test")
+		if !ok || !nexter.Next(testAdd.Add(-1)).Equal(testAdd) {
+			t.Fatalf("Nexter did not return the Set Nexter")
+		}
+
+		if _, ok := schedule.Nexter("unknown"); ok {
+			t.Fatalf("Nexter returned a Nexter for an unknown task")
+		}
+	})
+
 	t.Run("Tick should pass matching tasks to TaskRunner", func(t *testing.T) {
 		schedule := NewBasicSchedule()
 
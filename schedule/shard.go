@@ -0,0 +1,191 @@
+package schedule
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+// A TaskLookup provides the Nexter for each task, eg: a Crontab
+type TaskLookup interface {
+	Tasks() []string
+	Nexter(task string) (Nexter, bool)
+}
+
+// ShardOf returns the shard (from 0 to count-1) which owns the named task,
+// using a jump consistent hash of the name, so that changing the number of
+// shards moves as few tasks as possible.
+func ShardOf(task string, count int) int {
+	sum := md5.Sum([]byte(task))
+	key := binary.BigEndian.Uint64(sum[0:8])
+
+	shard, next := int64(-1), int64(0)
+	for next < int64(count) {
+		shard = next
+		key = key*2862933555777941757 + 1
+		next = int64(float64(shard+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(shard)
+}
+
+// A ShardedSchedule runs only those tasks of a TaskLookup which are owned by
+// one shard, so that several ecscron instances may share a crontab. When the
+// TaskLookup is a Chainer, tasks linked by dependencies are owned by the same
+// shard, as only the instance which launches a task can follow it through to
+// completion.
+type ShardedSchedule struct {
+	lookup      TaskLookup
+	index       int
+	count       int
+	concurrency int
+
+	// the name by which each linked task is hashed: the first (sorted) of
+	// the tasks linked to it
+	keys map[string]string
+}
+
+func NewShardedSchedule(lookup TaskLookup, index int, count int) *ShardedSchedule {
+	return &ShardedSchedule{lookup: lookup, index: index, count: count, keys: linkedKeys(lookup)}
+}
+
+// linkedKeys groups the tasks of a Chainer which are (indirectly) linked by
+// dependencies, returning the first (sorted) task of each group, by task
+func linkedKeys(lookup TaskLookup) map[string]string {
+	keys := make(map[string]string)
+	chainer, ok := lookup.(Chainer)
+	if !ok {
+		return keys
+	}
+
+	var find func(task string) string
+	find = func(task string) string {
+		key, ok := keys[task]
+		if !ok || key == task {
+			return task
+		}
+
+		key = find(key)
+		keys[task] = key
+		return key
+	}
+
+	for _, task := range lookup.Tasks() {
+		for _, dependent := range chainer.Dependents(task) {
+			a, b := find(task), find(dependent)
+			if a == b {
+				continue
+			}
+
+			if b < a {
+				a, b = b, a
+			}
+			keys[a] = a
+			keys[b] = a
+		}
+	}
+
+	for task := range keys {
+		keys[task] = find(task)
+	}
+
+	return keys
+}
+
+// SetConcurrency sets how many tasks may be launched at once within a tick
+// (see RunTasks). By default, tasks are launched one after another.
+func (s *ShardedSchedule) SetConcurrency(concurrency int) {
+	s.concurrency = concurrency
+}
+
+// Owns reports whether the named task belongs to this shard
+func (s *ShardedSchedule) Owns(task string) bool {
+	if key, ok := s.keys[task]; ok {
+		task = key
+	}
+
+	return ShardOf(task, s.count) == s.index
+}
+
+// Priority returns the priority of the named task, as given by the
+// TaskLookup (if it is a Prioritizer)
+func (s *ShardedSchedule) Priority(task string) int {
+	if prioritizer, ok := s.lookup.(Prioritizer); ok {
+		return prioritizer.Priority(task)
+	}
+
+	return 0
+}
+
+// Dependents returns the tasks to be run after the named task completes, as
+// given by the TaskLookup (if it is a Chainer), if owned by this shard
+func (s *ShardedSchedule) Dependents(task string) []string {
+	chainer, ok := s.lookup.(Chainer)
+	if !ok || !s.Owns(task) {
+		return nil
+	}
+
+	return chainer.Dependents(task)
+}
+
+// Upstreams returns the tasks which the named task is to be run after, as
+// given by the TaskLookup, if owned by this shard
+func (s *ShardedSchedule) Upstreams(task string) []string {
+	upstreamer, ok := s.lookup.(interface{ Upstreams(task string) []string })
+	if !ok || !s.Owns(task) {
+		return nil
+	}
+
+	return upstreamer.Upstreams(task)
+}
+
+// Tasks returns the (sorted) names of the tasks owned by this shard
+func (s *ShardedSchedule) Tasks() []string {
+	owned := []string{}
+	for _, task := range s.lookup.Tasks() {
+		if s.Owns(task) {
+			owned = append(owned, task)
+		}
+	}
+
+	return owned
+}
+
+// Nexter returns the Nexter for the named task, if owned by this shard
+func (s *ShardedSchedule) Nexter(task string) (Nexter, bool) {
+	if !s.Owns(task) {
+		return nil, false
+	}
+
+	return s.lookup.Nexter(task)
+}
+
+func (s *ShardedSchedule) Next(after time.Time) time.Time {
+	var earliest time.Time
+
+	for _, task := range s.Tasks() {
+		nexter, ok := s.lookup.Nexter(task)
+		if !ok {
+			continue
+		}
+
+		next := nexter.Next(after)
+		if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+			earliest = next
+
+			if earliest.Equal(after.Add(time.Nanosecond)) {
+				break
+			}
+		}
+	}
+
+	return earliest
+}
+
+func (s *ShardedSchedule) Tick(runner taskrunner.TaskRunner, at time.Time) (map[string]*taskrunner.TaskStatus, error) {
+	due := Due(s, at)
+	SortByPriority(due, s)
+	return RunTasks(runner, due, s.concurrency)
+}
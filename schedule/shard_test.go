@@ -0,0 +1,146 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func TestShardOf(t *testing.T) {
+	tasks := make([]string, 1000)
+	for i := range tasks {
+		tasks[i] = fmt.Sprintf("task-%d", i)
+	}
+
+	t.Run("Tasks should be spread evenly across shards", func(t *testing.T) {
+		counts := make([]int, 4)
+		for _, task := range tasks {
+			shard := ShardOf(task, 4)
+			if shard < 0 || shard >= 4 {
+				t.Fatalf("Task '%s' was assigned to shard %d, out of range", task, shard)
+			}
+			counts[shard] += 1
+		}
+
+		for shard, count := range counts {
+			if count < 200 || count > 300 {
+				t.Fatalf("Shard %d was assigned %d of 1000 tasks: %v", shard, count, counts)
+			}
+		}
+	})
+
+	t.Run("Adding a shard should only move tasks to the new shard", func(t *testing.T) {
+		moved := 0
+		for _, task := range tasks {
+			before, after := ShardOf(task, 4), ShardOf(task, 5)
+			if before == after {
+				continue
+			}
+
+			if after != 4 {
+				t.Fatalf("Task '%s' moved from shard %d to existing shard %d", task, before, after)
+			}
+			moved += 1
+		}
+
+		if moved < 150 || moved > 250 {
+			t.Fatalf("%d of 1000 tasks moved to the new shard", moved)
+		}
+	})
+
+	t.Run("A single shard should own everything", func(t *testing.T) {
+		for _, task := range tasks {
+			if ShardOf(task, 1) != 0 {
+				t.Fatalf("Task '%s' was not assigned to the only shard", task)
+			}
+		}
+	})
+}
+
+func TestShardedSchedule(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	base := NewBasicSchedule()
+	for i := 0; i < 20; i++ {
+		base.Set(fmt.Sprintf("task-%d", i), NextTime(at.Add(time.Duration(i)*time.Minute)))
+	}
+
+	t.Run("Each task should run in exactly one shard", func(t *testing.T) {
+		ran := make(map[string]int)
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			ran[task] += 1
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		for index := 0; index < 3; index++ {
+			sharded := NewShardedSchedule(base, index, 3)
+			next := sharded.Next(at.Add(-time.Second))
+			for !next.IsZero() {
+				results, err := sharded.Tick(runner, next)
+				if err != nil || len(results) != 1 {
+					t.Fatalf("Tick at %v did not run exactly one task: %v, %v", next, results, err)
+				}
+
+				for task := range results {
+					if !sharded.Owns(task) {
+						t.Fatalf("Shard %d ran task '%s' which it does not own", index, task)
+					}
+				}
+
+				next = sharded.Next(next)
+			}
+		}
+
+		if len(ran) != 20 {
+			t.Fatalf("Only %d of 20 tasks were run across all shards", len(ran))
+		}
+
+		for task, count := range ran {
+			if count != 1 {
+				t.Fatalf("Task '%s' was run %d times across all shards", task, count)
+			}
+		}
+	})
+
+	t.Run("Tasks linked by dependencies should be owned by the same shard", func(t *testing.T) {
+		// task-0 and task-1 are in different shards, unless linked
+		if ShardOf("task-0", 3) == ShardOf("task-1", 3) {
+			t.Fatalf("Test tasks should be in different shards")
+		}
+
+		chained := &chainedSchedule{BasicSchedule: base, then: map[string][]string{
+			"task-1": []string{"task-0"},
+		}}
+
+		for index := 0; index < 3; index++ {
+			sharded := NewShardedSchedule(chained, index, 3)
+			if sharded.Owns("task-0") != sharded.Owns("task-1") {
+				t.Fatalf("Linked tasks were split across shards")
+			}
+
+			if sharded.Owns("task-1") != (len(sharded.Dependents("task-1")) == 1) {
+				t.Fatalf("Dependents were not given only by the owning shard")
+			}
+		}
+	})
+
+	t.Run("Nexter should only return owned tasks", func(t *testing.T) {
+		sharded := NewShardedSchedule(base, 0, 3)
+		for _, task := range base.Tasks() {
+			if _, ok := sharded.Nexter(task); ok != sharded.Owns(task) {
+				t.Fatalf("Nexter for '%s' did not match ownership", task)
+			}
+		}
+	})
+}
+
+// chainedSchedule adds dependencies to a BasicSchedule
+type chainedSchedule struct {
+	*BasicSchedule
+	then map[string][]string
+}
+
+func (c *chainedSchedule) Dependents(task string) []string {
+	return c.then[task]
+}
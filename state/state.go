@@ -0,0 +1,77 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner/pause"
+)
+
+// State is persisted across restarts, when a state file is configured
+type State struct {
+	// the last tick which was completed
+	LastTick *time.Time `json:"last_tick,omitempty"`
+
+	// the number of attempts made so far, for each task awaiting a retry
+	Retries map[string]int64 `json:"retries,omitempty"`
+
+	// when each task deferred by a busy mutex group was originally scheduled
+	Deferred map[string]time.Time `json:"deferred,omitempty"`
+
+	// the task of each launched copy whose dependents await its completion
+	Awaiting map[string]string `json:"awaiting,omitempty"`
+
+	// the tasks which have succeeded, for each dependent still waiting for
+	// others
+	Satisfied map[string][]string `json:"satisfied,omitempty"`
+
+	PausedTasks []*pause.Pause `json:"paused_tasks"`
+}
+
+// Load reads the State from path. A missing file is an empty State.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Save writes the State to path, replacing it atomically so that an
+// interrupted write never leaves a partial file
+func Save(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
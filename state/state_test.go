@@ -0,0 +1,53 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner/pause"
+)
+
+func TestState(t *testing.T) {
+	t.Run("Save and Load should round-trip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		lastTick := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		err := Save(path, &State{
+			LastTick: &lastTick,
+			Retries:  map[string]int64{"test": 2},
+			PausedTasks: []*pause.Pause{
+				&pause.Pause{Pattern: "billing-*", Reason: "migration", Source: pause.SourceAPI},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error saving: %s", err)
+		}
+
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Unexpected error loading: %s", err)
+		}
+
+		if !loaded.LastTick.Equal(lastTick) || loaded.Retries["test"] != 2 {
+			t.Fatalf("Checkpoint was not loaded as saved: %+v", loaded)
+		}
+
+		if len(loaded.PausedTasks) != 1 || loaded.PausedTasks[0].Pattern != "billing-*" ||
+			loaded.PausedTasks[0].Reason != "migration" {
+			t.Fatalf("State was not loaded as saved: %+v", loaded)
+		}
+
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		if len(entries) != 1 {
+			t.Fatalf("Save left temporary files behind: %v", entries)
+		}
+	})
+
+	t.Run("Load of a missing file should be empty", func(t *testing.T) {
+		loaded, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+		if err != nil || len(loaded.PausedTasks) != 0 {
+			t.Fatalf("Missing state file was not empty: %+v %v", loaded, err)
+		}
+	})
+}
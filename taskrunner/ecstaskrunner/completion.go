@@ -0,0 +1,99 @@
+package ecstaskrunner
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+// DescribeTasks accepts at most this many tasks per call
+const describeTasksBatch = 100
+
+// A CompletionTracker follows tasks launched by an EcsTaskRunner through to
+// completion, via DescribeTasks
+type CompletionTracker struct {
+	service DescribeTaskser
+	cluster string
+}
+
+func NewCompletionTracker(service DescribeTaskser, cluster string) *CompletionTracker {
+	return &CompletionTracker{service: service, cluster: cluster}
+}
+
+// Launched returns the ARNs of the ECS tasks started for a successful run
+func (t *CompletionTracker) Launched(status *taskrunner.TaskStatus) []string {
+	output, ok := status.Output.(*ecs.RunTaskOutput)
+	if !ok || output == nil {
+		return nil
+	}
+
+	arns := []string{}
+	for _, task := range output.Tasks {
+		if arn := aws.StringValue(task.TaskArn); arn != "" {
+			arns = append(arns, arn)
+		}
+	}
+
+	return arns
+}
+
+// Stopped returns an entry for each of the given ARNs which has stopped: nil
+// if every container exited with code 0, otherwise the reason it failed. A
+// task which ECS no longer knows about is considered to have failed.
+func (t *CompletionTracker) Stopped(arns []string) (map[string]error, error) {
+	stopped := make(map[string]error)
+
+	for start := 0; start < len(arns); start += describeTasksBatch {
+		end := start + describeTasksBatch
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		input := &ecs.DescribeTasksInput{}
+		if t.cluster != "" {
+			input.SetCluster(t.cluster)
+		}
+		input.SetTasks(aws.StringSlice(arns[start:end]))
+
+		output, err := t.service.DescribeTasks(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range output.Tasks {
+			if aws.StringValue(task.LastStatus) != ecs.DesiredStatusStopped {
+				continue
+			}
+
+			stopped[aws.StringValue(task.TaskArn)] = taskFailure(task)
+		}
+
+		for _, failure := range output.Failures {
+			stopped[aws.StringValue(failure.Arn)] = fmt.Errorf("Task '%s' could not be described: %s",
+				aws.StringValue(failure.Arn), aws.StringValue(failure.Reason))
+		}
+	}
+
+	return stopped, nil
+}
+
+// taskFailure returns nil if every container of a stopped task exited with
+// code 0, otherwise the reason it failed
+func taskFailure(task *ecs.Task) error {
+	for _, container := range task.Containers {
+		if container.ExitCode == nil {
+			return fmt.Errorf("Container '%s' of task '%s' did not exit normally: %s",
+				aws.StringValue(container.Name), aws.StringValue(task.TaskArn),
+				aws.StringValue(task.StoppedReason))
+		}
+
+		if code := aws.Int64Value(container.ExitCode); code != 0 {
+			return fmt.Errorf("Container '%s' of task '%s' exited with code %d",
+				aws.StringValue(container.Name), aws.StringValue(task.TaskArn), code)
+		}
+	}
+
+	return nil
+}
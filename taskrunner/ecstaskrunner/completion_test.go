@@ -0,0 +1,106 @@
+package ecstaskrunner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/wpalmer/ecscron/taskrunner"
+)
+
+func stoppedTask(arn string, codes ...int64) *ecs.Task {
+	task := &ecs.Task{TaskArn: aws.String(arn), LastStatus: aws.String("STOPPED")}
+	for i, code := range codes {
+		task.Containers = append(task.Containers, &ecs.Container{
+			Name:     aws.String(fmt.Sprintf("container%d", i)),
+			ExitCode: aws.Int64(code),
+		})
+	}
+
+	return task
+}
+
+func TestCompletionTracker(t *testing.T) {
+	t.Run("Launched should give the ARNs of the started tasks", func(t *testing.T) {
+		status := &taskrunner.TaskStatus{Ran: true, Output: &ecs.RunTaskOutput{
+			Tasks: []*ecs.Task{{TaskArn: aws.String("arn:a")}, {TaskArn: aws.String("arn:b")}},
+		}}
+
+		arns := NewCompletionTracker(nil, "").Launched(status)
+		if len(arns) != 2 || arns[0] != "arn:a" || arns[1] != "arn:b" {
+			t.Fatalf("Unexpected ARNs: %v", arns)
+		}
+	})
+
+	t.Run("Stopped should only report stopped tasks, and why they failed", func(t *testing.T) {
+		service := describeTasksFunc(func(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+			if aws.StringValue(input.Cluster) != "clustername" {
+				t.Fatalf("DescribeTasks was not limited to the cluster")
+			}
+
+			return &ecs.DescribeTasksOutput{
+				Tasks: []*ecs.Task{
+					stoppedTask("arn:ok", 0, 0),
+					stoppedTask("arn:failed", 0, 2),
+					{TaskArn: aws.String("arn:running"), LastStatus: aws.String("RUNNING")},
+				},
+				Failures: []*ecs.Failure{{Arn: aws.String("arn:missing"), Reason: aws.String("MISSING")}},
+			}, nil
+		})
+
+		stopped, err := NewCompletionTracker(service, "clustername").Stopped(
+			[]string{"arn:ok", "arn:failed", "arn:running", "arn:missing"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if reason, ok := stopped["arn:ok"]; !ok || reason != nil {
+			t.Fatalf("Successful task was not reported as such: %v", reason)
+		}
+
+		if reason := stopped["arn:failed"]; reason == nil {
+			t.Fatalf("Non-zero exit code was not reported as a failure")
+		}
+
+		if reason := stopped["arn:missing"]; reason == nil {
+			t.Fatalf("Unknown task was not reported as a failure")
+		}
+
+		if _, ok := stopped["arn:running"]; ok {
+			t.Fatalf("Running task was reported as stopped")
+		}
+	})
+
+	t.Run("Stopped should describe tasks in batches", func(t *testing.T) {
+		calls := 0
+		service := describeTasksFunc(func(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+			calls += 1
+			if len(input.Tasks) > 100 {
+				t.Fatalf("Too many tasks described at once: %d", len(input.Tasks))
+			}
+
+			return &ecs.DescribeTasksOutput{}, nil
+		})
+
+		arns := make([]string, 150)
+		for i := range arns {
+			arns[i] = fmt.Sprintf("arn:%d", i)
+		}
+
+		if _, err := NewCompletionTracker(service, "").Stopped(arns); err != nil || calls != 2 {
+			t.Fatalf("Tasks were not described in 2 batches: %d calls, %v", calls, err)
+		}
+	})
+
+	t.Run("DescribeTasks errors should be returned", func(t *testing.T) {
+		service := describeTasksFunc(func(*ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+			return nil, errors.New("intentional error")
+		})
+
+		if _, err := NewCompletionTracker(service, "").Stopped([]string{"arn:a"}); err == nil {
+			t.Fatalf("Error was not returned")
+		}
+	})
+}
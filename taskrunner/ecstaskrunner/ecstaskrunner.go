@@ -3,8 +3,12 @@ package ecstaskrunner
 import (
 	"crypto/md5"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/wpalmer/ecscron/taskrunner"
 )
@@ -17,36 +21,178 @@ type ListTaskser interface {
 	ListTasks(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
 }
 
+type DescribeTaskser interface {
+	DescribeTasks(*ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+}
+
+type DescribeTaskDefinitioner interface {
+	DescribeTaskDefinition(*ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error)
+}
+
 type MinimalECSAPI interface {
 	ListTaskser
 	RunTasker
+	DescribeTaskser
+	DescribeTaskDefinitioner
+}
+
+// An Environmenter gives the environment variables to set in every container
+// of a task, if any
+type Environmenter interface {
+	Environment(task string) map[string]string
+}
+
+// An EnvironmentMap is an Environmenter of the variables for each task
+type EnvironmentMap map[string]map[string]string
+
+func (m EnvironmentMap) Environment(task string) map[string]string {
+	return m[task]
+}
+
+// An Identifier gives the name by which the copies of a task are started (and
+// so found) on the cluster, eg: its task definition
+type Identifier func(task string) string
+
+// startedBy gives the startedBy of the copies of a task: the md5 of its name,
+// or of the name given by the Identifier, if any
+func startedBy(identify Identifier, task string) string {
+	if identify != nil {
+		task = identify(task)
+	}
+
+	return fmt.Sprintf("%x", md5.Sum([]byte(task)))
 }
 
 type EcsTaskRunner struct {
-	service RunTasker
-	cluster string
+	service     RunTasker
+	cluster     string
+	environment Environmenter
+	definitions DescribeTaskDefinitioner
+	identify    Identifier
 }
 
 type EcsSkipRunningTaskRunner struct {
-	service ListTaskser
-	cluster string
-	runner  taskrunner.TaskRunner
+	service  ListTaskser
+	cluster  string
+	runner   taskrunner.TaskRunner
+	identify Identifier
 }
 
 func NewEcsTaskRunner(service RunTasker, cluster string) *EcsTaskRunner {
 	return &EcsTaskRunner{service: service, cluster: cluster}
 }
 
+// SetEnvironment sets the environment variables given to the containers of
+// each task. As ECS overrides are per-container, the containers of each task
+// with variables are found via DescribeTaskDefinition.
+func (r *EcsTaskRunner) SetEnvironment(environment Environmenter, definitions DescribeTaskDefinitioner) {
+	r.environment = environment
+	r.definitions = definitions
+}
+
+// SetIdentifier sets the name by which the copies of each task are started,
+// in place of the name of the task itself
+func (r *EcsTaskRunner) SetIdentifier(identify Identifier) {
+	r.identify = identify
+}
+
+// overrides returns the container overrides giving the task's environment
+// variables, if any, for the containers of its task definition
+func (r *EcsTaskRunner) overrides(task string, definition string) (*ecs.TaskOverride, error) {
+	if r.environment == nil {
+		return nil, nil
+	}
+
+	environment := r.environment.Environment(task)
+	if len(environment) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(environment))
+	for name := range environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := []*ecs.KeyValuePair{}
+	for _, name := range names {
+		variables = append(variables, &ecs.KeyValuePair{
+			Name:  aws.String(name),
+			Value: aws.String(environment[name]),
+		})
+	}
+
+	input := &ecs.DescribeTaskDefinitionInput{}
+	input.SetTaskDefinition(definition)
+	output, err := r.definitions.DescribeTaskDefinition(input)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &ecs.TaskOverride{}
+	if output.TaskDefinition != nil {
+		for _, container := range output.TaskDefinition.ContainerDefinitions {
+			overrides.ContainerOverrides = append(overrides.ContainerOverrides, &ecs.ContainerOverride{
+				Name:        container.Name,
+				Environment: variables,
+			})
+		}
+	}
+
+	return overrides, nil
+}
+
 func (r *EcsTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	return r.RunTaskDefinition(task, task)
+}
+
+// RunTaskDefinition runs a task from the given task definition. The task is
+// started by (see SetIdentifier) and given the environment of its own name,
+// so that copies of tasks sharing a definition are told apart.
+func (r *EcsTaskRunner) RunTaskDefinition(task string, definition string) (*taskrunner.TaskStatus, error) {
 	runInput := &ecs.RunTaskInput{}
 	if r.cluster != "" {
 		runInput.SetCluster(r.cluster)
 	}
 
-	startedBy := fmt.Sprintf("%x", md5.Sum([]byte(task)))
-	runInput.SetStartedBy(startedBy)
-	runInput.SetTaskDefinition(task)
+	runInput.SetStartedBy(startedBy(r.identify, task))
+	runInput.SetTaskDefinition(definition)
+
+	overrides, err := r.overrides(task, definition)
+	if IsThrottling(err) {
+		return &taskrunner.TaskStatus{
+			Ran:       false,
+			Throttled: true,
+			Error:     nil,
+			Warnings:  []error{err},
+			Output:    nil,
+		}, nil
+	}
+
+	if err != nil {
+		return &taskrunner.TaskStatus{
+			Ran:      false,
+			Error:    fmt.Errorf("Failed to describe containers of '%s' for environment overrides: %w", definition, err),
+			Warnings: []error{},
+			Output:   nil,
+		}, nil
+	}
+
+	if overrides != nil {
+		runInput.SetOverrides(overrides)
+	}
+
 	runResult, err := r.service.RunTask(runInput)
+	if IsThrottling(err) {
+		return &taskrunner.TaskStatus{
+			Ran:       false,
+			Throttled: true,
+			Error:     nil,
+			Warnings:  []error{err},
+			Output:    runResult,
+		}, nil
+	}
+
 	if err != nil {
 		return &taskrunner.TaskStatus{
 			Ran:      false,
@@ -61,7 +207,7 @@ func (r *EcsTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
 		for _, failure := range runResult.Failures {
 			warnings = append(warnings,
 				fmt.Errorf("Failure during RunTask '%s' on cluster '%s': %s",
-					task, r.cluster, strings.Replace(failure.GoString(), "\n", " ", -1)))
+					definition, r.cluster, strings.Replace(failure.GoString(), "\n", " ", -1)))
 		}
 		return &taskrunner.TaskStatus{
 			Ran:      false,
@@ -83,23 +229,56 @@ func NewEcsSkipRunningTaskRunner(service ListTaskser, cluster string, runner tas
 	return &EcsSkipRunningTaskRunner{service: service, cluster: cluster, runner: runner}
 }
 
-func (r *EcsSkipRunningTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+// SetIdentifier sets the name by which the copies of each task are found, in
+// place of the name of the task itself (see EcsTaskRunner.SetIdentifier)
+func (r *EcsSkipRunningTaskRunner) SetIdentifier(identify Identifier) {
+	r.identify = identify
+}
+
+// IsRunning reports whether a copy of the task, started by ecscron, is
+// running on the cluster
+func (r *EcsSkipRunningTaskRunner) IsRunning(task string) (bool, error) {
 	listInput := &ecs.ListTasksInput{}
 	if r.cluster != "" {
 		listInput.SetCluster(r.cluster)
 	}
 
-	startedBy := fmt.Sprintf("%x", md5.Sum([]byte(task)))
-	listInput.SetStartedBy(startedBy)
+	listInput.SetStartedBy(startedBy(r.identify, task))
 	listInput.SetMaxResults(1)
 	listResult, err := r.service.ListTasks(listInput)
+	if err != nil {
+		return false, err
+	}
+
+	return len(listResult.TaskArns) > 0, nil
+}
+
+func (r *EcsSkipRunningTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	return r.RunTaskDefinition(task, task)
+}
+
+// RunTaskDefinition skips the task if a copy of it (by its own name, not the
+// definition) is running, otherwise passes both names to the inner runner (if
+// it is a DefinitionTaskRunner)
+func (r *EcsSkipRunningTaskRunner) RunTaskDefinition(task string, definition string) (*taskrunner.TaskStatus, error) {
+	running, err := r.IsRunning(task)
+	if IsThrottling(err) {
+		return &taskrunner.TaskStatus{
+			Ran:       false,
+			Throttled: true,
+			Error:     nil,
+			Warnings:  []error{err},
+			Output:    nil,
+		}, nil
+	}
+
 	if err != nil {
 		return nil,
 			fmt.Errorf("Failed to ListTasks looking for '%s' on cluster '%s': %s",
 				task, r.cluster, err)
 	}
 
-	if len(listResult.TaskArns) > 0 {
+	if running {
 		return &taskrunner.TaskStatus{
 			Ran:     false,
 			Running: true,
@@ -112,5 +291,138 @@ func (r *EcsSkipRunningTaskRunner) RunTask(task string) (*taskrunner.TaskStatus,
 		}, nil
 	}
 
-	return r.runner.RunTask(task)
+	if runner, ok := r.runner.(taskrunner.DefinitionTaskRunner); ok {
+		return runner.RunTaskDefinition(task, definition)
+	}
+
+	return r.runner.RunTask(definition)
+}
+
+// An ObserveFunc is called after each ECS API call, eg: to collect metrics
+type ObserveFunc func(operation string, duration time.Duration, err error)
+
+type ObservedService struct {
+	service MinimalECSAPI
+	observe ObserveFunc
+}
+
+// NewObservedService wraps an ECS API, calling "observe" after each call
+func NewObservedService(service MinimalECSAPI, observe ObserveFunc) *ObservedService {
+	return &ObservedService{service: service, observe: observe}
+}
+
+func (s *ObservedService) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	start := time.Now()
+	output, err := s.service.RunTask(input)
+	s.observe("RunTask", time.Since(start), err)
+
+	return output, err
+}
+
+func (s *ObservedService) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	start := time.Now()
+	output, err := s.service.ListTasks(input)
+	s.observe("ListTasks", time.Since(start), err)
+
+	return output, err
+}
+
+func (s *ObservedService) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	start := time.Now()
+	output, err := s.service.DescribeTasks(input)
+	s.observe("DescribeTasks", time.Since(start), err)
+
+	return output, err
+}
+
+func (s *ObservedService) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	start := time.Now()
+	output, err := s.service.DescribeTaskDefinition(input)
+	s.observe("DescribeTaskDefinition", time.Since(start), err)
+
+	return output, err
+}
+
+// IsCapacityFailure reports whether a task was not run because the cluster
+// lacked the resources (eg: memory, CPU, ports) to place it
+func IsCapacityFailure(status *taskrunner.TaskStatus) bool {
+	output, ok := status.Output.(*ecs.RunTaskOutput)
+	if status.Ran || !ok || output == nil {
+		return false
+	}
+
+	for _, failure := range output.Failures {
+		if strings.HasPrefix(aws.StringValue(failure.Reason), "RESOURCE:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A Prioritizer gives the priority of each task, eg: a Crontab
+type Prioritizer interface {
+	Priority(task string) int
+}
+
+// The Warning given for any task which is not run due to an earlier capacity
+// failure
+type CapacitySkippedError struct {
+	Task     string
+	Priority int
+
+	// the priority of the task which could not be placed
+	Failed int
+}
+
+func (e *CapacitySkippedError) Error() string {
+	return fmt.Sprintf("Skipping Task '%s' (priority %d), as a task of priority %d could not be placed due to lack of capacity",
+		e.Task, e.Priority, e.Failed)
+}
+
+// A CapacityGuardTaskRunner stops launching tasks once the cluster reports a
+// capacity failure, other than those of at least the same priority as the
+// task which failed. It is intended to be used for a single tick, and is safe
+// for concurrent use.
+type CapacityGuardTaskRunner struct {
+	mu          sync.Mutex
+	runner      taskrunner.TaskRunner
+	prioritizer Prioritizer
+	failed      bool
+	floor       int
+}
+
+func NewCapacityGuardTaskRunner(runner taskrunner.TaskRunner, prioritizer Prioritizer) *CapacityGuardTaskRunner {
+	return &CapacityGuardTaskRunner{runner: runner, prioritizer: prioritizer}
+}
+
+func (r *CapacityGuardTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	priority := r.prioritizer.Priority(task)
+
+	r.mu.Lock()
+	failed, floor := r.failed, r.floor
+	r.mu.Unlock()
+
+	if failed && priority < floor {
+		return &taskrunner.TaskStatus{
+			Ran:      false,
+			Error:    nil,
+			Warnings: []error{&CapacitySkippedError{Task: task, Priority: priority, Failed: floor}},
+			Output:   nil,
+		}, nil
+	}
+
+	status, err := r.runner.RunTask(task)
+	if err != nil || !IsCapacityFailure(status) {
+		return status, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.failed || priority > r.floor {
+		r.failed = true
+		r.floor = priority
+	}
+
+	return status, err
 }
@@ -1,13 +1,17 @@
 package ecstaskrunner
 
 import (
+	"crypto/md5"
 	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/tweak"
 )
 
 type listTasksFunc func(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
@@ -22,6 +26,18 @@ func (f runTaskFunc) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error
 	return f(input)
 }
 
+type describeTasksFunc func(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+
+func (f describeTasksFunc) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	return f(input)
+}
+
+type describeTaskDefinitionFunc func(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error)
+
+func (f describeTaskDefinitionFunc) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	return f(input)
+}
+
 func TestEcsSkipRunningTaskRunner(t *testing.T) {
 	t.Run("ListTask errors should be returned as errors", func(t *testing.T) {
 		service := listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
@@ -209,3 +225,272 @@ func TestEcsTaskRunner(t *testing.T) {
 		}
 	})
 }
+
+type minimalService struct {
+	listTasksFunc
+	runTaskFunc
+	describeTasksFunc
+	describeTaskDefinitionFunc
+}
+
+func TestEcsTaskRunnerEnvironment(t *testing.T) {
+	definitions := describeTaskDefinitionFunc(func(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+		if aws.StringValue(input.TaskDefinition) != "report-a" {
+			return nil, errors.New("unknown task definition")
+		}
+
+		return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{
+			ContainerDefinitions: []*ecs.ContainerDefinition{
+				{Name: aws.String("app")},
+				{Name: aws.String("sidecar")},
+			},
+		}}, nil
+	})
+
+	t.Run("Environment variables should be given to every container", func(t *testing.T) {
+		var overrides *ecs.TaskOverride
+		service := runTaskFunc(func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+			overrides = input.Overrides
+			return &ecs.RunTaskOutput{}, nil
+		})
+
+		runner := NewEcsTaskRunner(service, "clustername")
+		runner.SetEnvironment(EnvironmentMap{"report-a": {"TENANT": "a", "REGION": "eu"}}, definitions)
+		if result, err := runner.RunTask("report-a"); err != nil || !result.Ran {
+			t.Fatalf("RunTask did not succeed: %+v %v", result, err)
+		}
+
+		if overrides == nil || len(overrides.ContainerOverrides) != 2 {
+			t.Fatalf("Overrides were not given for each container: %v", overrides)
+		}
+
+		for _, container := range overrides.ContainerOverrides {
+			variables := []string{}
+			for _, variable := range container.Environment {
+				variables = append(variables, aws.StringValue(variable.Name)+"="+aws.StringValue(variable.Value))
+			}
+
+			if strings.Join(variables, ",") != "REGION=eu,TENANT=a" {
+				t.Fatalf("Unexpected environment of '%s': %v", aws.StringValue(container.Name), variables)
+			}
+		}
+
+		if _, err := runner.RunTask("other"); err != nil || overrides != nil {
+			t.Fatalf("Overrides were given to a task without variables: %v", overrides)
+		}
+	})
+
+	t.Run("A failure to describe the containers should fail the task", func(t *testing.T) {
+		service := runTaskFunc(func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+			t.Fatalf("RunTask was called without the environment")
+			return nil, nil
+		})
+
+		runner := NewEcsTaskRunner(service, "clustername")
+		runner.SetEnvironment(EnvironmentMap{"report-b": {"TENANT": "b"}}, definitions)
+		result, err := runner.RunTask("report-b")
+		if err != nil || result.Ran || result.Error == nil {
+			t.Fatalf("Failure to describe the containers was not reported as an error: %+v %v", result, err)
+		}
+	})
+}
+
+func TestEcsTaskRunnerSharedDefinition(t *testing.T) {
+	t.Run("Tasks sharing a definition should keep their own environment and startedBy", func(t *testing.T) {
+		running := map[string]bool{}
+		environments := map[string]string{}
+		service := minimalService{
+			listTasksFunc: func(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				if running[aws.StringValue(input.StartedBy)] {
+					return &ecs.ListTasksOutput{TaskArns: []*string{aws.String("arn")}}, nil
+				}
+
+				return &ecs.ListTasksOutput{}, nil
+			},
+			runTaskFunc: func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+				if definition := aws.StringValue(input.TaskDefinition); definition != "report" {
+					t.Fatalf("Unexpected task definition: %s", definition)
+				}
+
+				startedBy := aws.StringValue(input.StartedBy)
+				running[startedBy] = true
+				environments[startedBy] = aws.StringValue(input.Overrides.ContainerOverrides[0].Environment[0].Value)
+				return &ecs.RunTaskOutput{}, nil
+			},
+			describeTaskDefinitionFunc: func(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+				return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: &ecs.TaskDefinition{
+					ContainerDefinitions: []*ecs.ContainerDefinition{{Name: aws.String("app")}},
+				}}, nil
+			},
+		}
+
+		inner := NewEcsTaskRunner(service, "clustername")
+		inner.SetEnvironment(EnvironmentMap{
+			"report-a": {"TENANT": "a"},
+			"report-b": {"TENANT": "b"},
+		}, service)
+		runner := tweak.NewTweakTaskRunner(NewEcsSkipRunningTaskRunner(service, "clustername", inner),
+			func(task string) string { return "report" })
+
+		for _, task := range []string{"report-a", "report-b"} {
+			if result, err := runner.RunTask(task); err != nil || !result.Ran {
+				t.Fatalf("'%s' was not run: %+v %v", task, result, err)
+			}
+		}
+
+		a := fmt.Sprintf("%x", md5.Sum([]byte("report-a")))
+		b := fmt.Sprintf("%x", md5.Sum([]byte("report-b")))
+		if len(environments) != 2 || environments[a] != "a" || environments[b] != "b" {
+			t.Fatalf("Tasks were not started by, and given the environment of, their own names: %v", environments)
+		}
+
+		if result, _ := runner.RunTask("report-a"); result.Ran || !result.Running {
+			t.Fatalf("A running task sharing a definition was not skipped: %+v", result)
+		}
+	})
+
+	t.Run("Tasks not sharing a definition should be started by their definition", func(t *testing.T) {
+		var started string
+		service := minimalService{
+			listTasksFunc: func(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				// as left running by a version before definitions were shared
+				if aws.StringValue(input.StartedBy) == fmt.Sprintf("%x", md5.Sum([]byte("prod-report-c"))) {
+					return &ecs.ListTasksOutput{TaskArns: []*string{aws.String("arn")}}, nil
+				}
+
+				return &ecs.ListTasksOutput{}, nil
+			},
+			runTaskFunc: func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+				started = aws.StringValue(input.StartedBy)
+				return &ecs.RunTaskOutput{}, nil
+			},
+		}
+
+		translate := func(task string) string {
+			if task == "report-c" {
+				return "prod-report-c"
+			}
+
+			return "prod-report"
+		}
+
+		identify := Identifier(tweak.Identify([]string{"report-a", "report-b", "report-c"}, translate))
+		inner := NewEcsTaskRunner(service, "clustername")
+		inner.SetIdentifier(identify)
+		skipper := NewEcsSkipRunningTaskRunner(service, "clustername", inner)
+		skipper.SetIdentifier(identify)
+		runner := tweak.NewTweakTaskRunner(skipper, translate)
+
+		if result, _ := runner.RunTask("report-c"); result.Ran || !result.Running {
+			t.Fatalf("A running task, started by its definition, was not skipped: %+v", result)
+		}
+
+		if result, err := runner.RunTask("report-a"); err != nil || !result.Ran {
+			t.Fatalf("'report-a' was not run: %+v %v", result, err)
+		}
+
+		if started != fmt.Sprintf("%x", md5.Sum([]byte("report-a"))) {
+			t.Fatalf("A task sharing a definition was not started by its own name: %s", started)
+		}
+	})
+}
+
+func TestObservedService(t *testing.T) {
+	t.Run("Each call should be observed", func(t *testing.T) {
+		service := minimalService{
+			listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				return nil, errors.New("intentional error")
+			}),
+			runTaskFunc(func(*ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+				return &ecs.RunTaskOutput{}, nil
+			}),
+			describeTasksFunc(nil),
+			describeTaskDefinitionFunc(nil),
+		}
+
+		observed := make(map[string]error)
+		observer := NewObservedService(service, func(operation string, duration time.Duration, err error) {
+			observed[operation] = err
+		})
+
+		if _, err := observer.ListTasks(&ecs.ListTasksInput{}); err == nil {
+			t.Fatalf("An error from ListTasks was not passed-through")
+		}
+
+		if _, err := observer.RunTask(&ecs.RunTaskInput{}); err != nil {
+			t.Fatalf("RunTask resulted in an unexpected error: %s", err)
+		}
+
+		if err, ok := observed["ListTasks"]; !ok || err == nil {
+			t.Fatalf("ListTasks was not observed with its error")
+		}
+
+		if err, ok := observed["RunTask"]; !ok || err != nil {
+			t.Fatalf("RunTask was not observed as successful")
+		}
+	})
+}
+
+type priorities map[string]int
+
+func (p priorities) Priority(task string) int {
+	return p[task]
+}
+
+func TestCapacityGuardTaskRunner(t *testing.T) {
+	t.Run("Lower-priority tasks should be skipped after a capacity failure", func(t *testing.T) {
+		service := runTaskFunc(func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+			if *input.TaskDefinition == "full" {
+				return &ecs.RunTaskOutput{Failures: []*ecs.Failure{
+					&ecs.Failure{Reason: aws.String("RESOURCE:MEMORY")},
+				}}, nil
+			}
+
+			return &ecs.RunTaskOutput{}, nil
+		})
+
+		guard := NewCapacityGuardTaskRunner(NewEcsTaskRunner(service, "test"),
+			priorities{"full": 5, "peer": 5, "high": 10})
+
+		if result, _ := guard.RunTask("low"); !result.Ran {
+			t.Fatalf("Task was not run before any capacity failure")
+		}
+
+		if result, _ := guard.RunTask("full"); result.Ran || !IsCapacityFailure(result) {
+			t.Fatalf("Capacity failure was not recognised: %+v", result)
+		}
+
+		result, _ := guard.RunTask("low")
+		if result.Ran || len(result.Warnings) != 1 {
+			t.Fatalf("Lower-priority task was not skipped after a capacity failure: %+v", result)
+		}
+
+		if _, ok := result.Warnings[0].(*CapacitySkippedError); !ok {
+			t.Fatalf("Skipped task did not have a CapacitySkippedError warning: %v", result.Warnings[0])
+		}
+
+		for _, task := range []string{"peer", "high"} {
+			if result, _ := guard.RunTask(task); !result.Ran {
+				t.Fatalf("Task '%s' of at least the same priority was skipped", task)
+			}
+		}
+	})
+
+	t.Run("Other failures should not stop launching", func(t *testing.T) {
+		service := runTaskFunc(func(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+			return &ecs.RunTaskOutput{Failures: []*ecs.Failure{
+				&ecs.Failure{Reason: aws.String("MISSING")},
+			}}, nil
+		})
+
+		guard := NewCapacityGuardTaskRunner(NewEcsTaskRunner(service, "test"), priorities{"first": 1})
+		_, _ = guard.RunTask("first")
+
+		result, _ := guard.RunTask("second")
+		for _, warning := range result.Warnings {
+			if _, ok := warning.(*CapacitySkippedError); ok {
+				t.Fatalf("Task was skipped after a failure unrelated to capacity")
+			}
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package ecstaskrunner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// IsThrottling reports whether an ECS API error means that the request was
+// throttled, rather than having failed
+func IsThrottling(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "ThrottlingException", "Throttling", "RequestLimitExceeded":
+		return true
+	}
+
+	return false
+}
+
+// A TokenBucket limits the rate of some operation to "rate" per second, on
+// average, while allowing bursts of up to "burst" at once. It is safe for
+// concurrent use.
+type TokenBucket struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	blocked time.Time
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// reserve takes a token, returning how long the caller must wait before
+// proceeding
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	// tokens may go negative, queueing callers in the order they arrived
+	b.tokens -= 1
+	wait := time.Duration(0)
+	if b.tokens < 0 && b.rate > 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+
+	if blocked := b.blocked.Sub(now); blocked > wait {
+		wait = blocked
+	}
+
+	return wait
+}
+
+// Wait blocks until the operation may proceed
+func (b *TokenBucket) Wait() {
+	if wait := b.reserve(); wait > 0 {
+		b.sleep(wait)
+	}
+}
+
+// Block holds every caller of Wait until at least the given duration has
+// passed, eg: after being throttled
+func (b *TokenBucket) Block(duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := b.now().Add(duration); until.After(b.blocked) {
+		b.blocked = until
+	}
+}
+
+// A RateLimitedService wraps an ECS API, limiting the rate of calls (if given
+// a TokenBucket), and retrying any call which is throttled, with an
+// exponential backoff which is applied to all calls
+type RateLimitedService struct {
+	service     MinimalECSAPI
+	bucket      *TokenBucket
+	maxAttempts int
+	backoff     time.Duration
+	sleep       func(time.Duration)
+}
+
+// NewRateLimitedService wraps an ECS API. bucket may be nil, to only back off
+// when throttled.
+func NewRateLimitedService(service MinimalECSAPI, bucket *TokenBucket) *RateLimitedService {
+	return &RateLimitedService{
+		service:     service,
+		bucket:      bucket,
+		maxAttempts: 4,
+		backoff:     time.Second,
+		sleep:       time.Sleep,
+	}
+}
+
+// call makes an API call, waiting for the bucket and retrying while throttled.
+// The error of the last attempt is returned, so may still be throttling.
+func (s *RateLimitedService) call(f func() error) error {
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		if s.bucket != nil {
+			s.bucket.Wait()
+		}
+
+		err := f()
+		if !IsThrottling(err) || attempt >= s.maxAttempts {
+			return err
+		}
+
+		if s.bucket != nil {
+			s.bucket.Block(backoff)
+		} else {
+			s.sleep(backoff)
+		}
+		backoff *= 2
+	}
+}
+
+func (s *RateLimitedService) RunTask(input *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+	var output *ecs.RunTaskOutput
+	err := s.call(func() error {
+		var err error
+		output, err = s.service.RunTask(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (s *RateLimitedService) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	var output *ecs.ListTasksOutput
+	err := s.call(func() error {
+		var err error
+		output, err = s.service.ListTasks(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (s *RateLimitedService) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	var output *ecs.DescribeTasksOutput
+	err := s.call(func() error {
+		var err error
+		output, err = s.service.DescribeTasks(input)
+		return err
+	})
+
+	return output, err
+}
+
+func (s *RateLimitedService) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	var output *ecs.DescribeTaskDefinitionOutput
+	err := s.call(func() error {
+		var err error
+		output, err = s.service.DescribeTaskDefinition(input)
+		return err
+	})
+
+	return output, err
+}
@@ -0,0 +1,156 @@
+package ecstaskrunner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func throttlingError() error {
+	return awserr.New("ThrottlingException", "Rate exceeded", nil)
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Run("Should allow a burst, then limit the rate", func(t *testing.T) {
+		now := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		slept := time.Duration(0)
+
+		bucket := NewTokenBucket(2, 3)
+		bucket.now = func() time.Time { return now }
+		bucket.sleep = func(d time.Duration) { slept += d }
+
+		for i := 0; i < 3; i++ {
+			bucket.Wait()
+		}
+
+		if slept != 0 {
+			t.Fatalf("Waited %v within the burst", slept)
+		}
+
+		bucket.Wait()
+		bucket.Wait()
+		if slept != 1500*time.Millisecond {
+			t.Fatalf("Waited %v rather than 0.5s and 1s beyond the burst, at 2 per second", slept)
+		}
+
+		now = now.Add(time.Hour)
+		slept = 0
+		bucket.Wait()
+		if slept != 0 {
+			t.Fatalf("Waited %v after the bucket had refilled", slept)
+		}
+	})
+
+	t.Run("Block should hold callers", func(t *testing.T) {
+		now := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+		slept := time.Duration(0)
+
+		bucket := NewTokenBucket(100, 100)
+		bucket.now = func() time.Time { return now }
+		bucket.sleep = func(d time.Duration) { slept += d }
+
+		bucket.Block(5 * time.Second)
+		bucket.Wait()
+		if slept != 5*time.Second {
+			t.Fatalf("Waited %v rather than the blocked 5s", slept)
+		}
+	})
+}
+
+func TestRateLimitedService(t *testing.T) {
+	t.Run("Throttled calls should be retried with a backoff", func(t *testing.T) {
+		calls := 0
+		service := minimalService{
+			listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				return &ecs.ListTasksOutput{}, nil
+			}),
+			runTaskFunc(func(*ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+				calls += 1
+				if calls < 3 {
+					return nil, throttlingError()
+				}
+				return &ecs.RunTaskOutput{}, nil
+			}),
+			describeTasksFunc(nil),
+			describeTaskDefinitionFunc(nil),
+		}
+
+		slept := []time.Duration{}
+		limited := NewRateLimitedService(service, nil)
+		limited.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+		if _, err := limited.RunTask(&ecs.RunTaskInput{}); err != nil {
+			t.Fatalf("Unexpected error after retrying: %s", err)
+		}
+
+		if calls != 3 || len(slept) != 2 || slept[0] != time.Second || slept[1] != 2*time.Second {
+			t.Fatalf("Throttled calls were not retried with an exponential backoff: %d, %v", calls, slept)
+		}
+	})
+
+	t.Run("Persistent throttling should be returned", func(t *testing.T) {
+		calls := 0
+		service := minimalService{
+			listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				calls += 1
+				return nil, throttlingError()
+			}),
+			runTaskFunc(nil),
+			describeTasksFunc(nil),
+			describeTaskDefinitionFunc(nil),
+		}
+
+		limited := NewRateLimitedService(service, nil)
+		limited.sleep = func(time.Duration) {}
+
+		if _, err := limited.ListTasks(&ecs.ListTasksInput{}); !IsThrottling(err) {
+			t.Fatalf("Persistent throttling was not returned: %v", err)
+		}
+
+		if calls != limited.maxAttempts {
+			t.Fatalf("Made %d calls rather than %d", calls, limited.maxAttempts)
+		}
+	})
+
+	t.Run("Other errors should not be retried", func(t *testing.T) {
+		calls := 0
+		service := minimalService{
+			listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+				calls += 1
+				return nil, errors.New("intentional error")
+			}),
+			runTaskFunc(nil),
+			describeTasksFunc(nil),
+			describeTaskDefinitionFunc(nil),
+		}
+
+		if _, err := NewRateLimitedService(service, nil).ListTasks(&ecs.ListTasksInput{}); err == nil || calls != 1 {
+			t.Fatalf("Error was not returned immediately: %d calls", calls)
+		}
+	})
+}
+
+func TestThrottledTaskRunners(t *testing.T) {
+	t.Run("Throttling should be a transient outcome rather than an error", func(t *testing.T) {
+		runner := NewEcsTaskRunner(runTaskFunc(func(*ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
+			return nil, throttlingError()
+		}), "test")
+
+		result, err := runner.RunTask("test")
+		if err != nil || result.Ran || !result.Throttled || result.Error != nil {
+			t.Fatalf("Throttled RunTask was not reported as throttled: %+v, %v", result, err)
+		}
+
+		skipper := NewEcsSkipRunningTaskRunner(listTasksFunc(func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+			return nil, throttlingError()
+		}), "test", runner)
+
+		result, err = skipper.RunTask("test")
+		if err != nil || result.Ran || !result.Throttled {
+			t.Fatalf("Throttled ListTasks was not reported as throttled: %+v, %v", result, err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package pause
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+const (
+	// paused by the control file
+	SourceFile = "file"
+
+	// paused at runtime, eg: via the HTTP API
+	SourceAPI = "api"
+)
+
+// A Pause prevents any task matching Pattern from running
+type Pause struct {
+	Pattern string `json:"pattern"`
+
+	// (optional) when the Pause ends
+	Until *time.Time `json:"until,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+	Source string `json:"source"`
+}
+
+func (p *Pause) Expired(at time.Time) bool {
+	return p.Until != nil && !at.Before(*p.Until)
+}
+
+// The Warning given for any task which is not run due to a Pause
+type PausedError struct {
+	Pause *Pause
+}
+
+func (e *PausedError) Error() string {
+	message := fmt.Sprintf("Skipping task paused by '%s'", e.Pause.Pattern)
+	if e.Pause.Until != nil {
+		message = fmt.Sprintf("%s (until %v)", message, *e.Pause.Until)
+	}
+
+	if e.Pause.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, e.Pause.Reason)
+	}
+
+	return message
+}
+
+func (e *PausedError) Deliberate() {}
+
+// A Set holds the paused tasks, both from the control file and those paused
+// at runtime
+type Set struct {
+	mu   sync.Mutex
+	file []*Pause
+	api  map[string]*Pause
+}
+
+func NewSet() *Set {
+	return &Set{api: make(map[string]*Pause)}
+}
+
+// Pause pauses every task matching the pattern (as understood by path.Match),
+// replacing any existing runtime Pause for the same pattern
+func (s *Set) Pause(pattern string, until *time.Time, reason string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("Invalid task pattern '%s': %w", pattern, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.api[pattern] = &Pause{Pattern: pattern, Until: until, Reason: reason, Source: SourceAPI}
+	return nil
+}
+
+// Resume removes the runtime Pause for exactly the given pattern, returning
+// false if there was none. Pauses from the control file can only be removed
+// by editing the file.
+func (s *Set) Resume(pattern string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.api[pattern]; !ok {
+		return false
+	}
+
+	delete(s.api, pattern)
+	return true
+}
+
+// SetFile replaces the Pauses from the control file
+func (s *Set) SetFile(pauses []*Pause) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file = pauses
+}
+
+// Active returns the Pauses which have not expired "at" the given time,
+// sorted by pattern. Expired runtime Pauses are forgotten.
+func (s *Set) Active(at time.Time) []*Pause {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := []*Pause{}
+	for pattern, p := range s.api {
+		if p.Expired(at) {
+			delete(s.api, pattern)
+			continue
+		}
+		active = append(active, p)
+	}
+
+	for _, p := range s.file {
+		if !p.Expired(at) {
+			active = append(active, p)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Pattern < active[j].Pattern })
+	return active
+}
+
+// Runtime returns the runtime Pauses (ie: those which should be persisted),
+// sorted by pattern
+func (s *Set) Runtime() []*Pause {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pauses := []*Pause{}
+	for _, p := range s.api {
+		pauses = append(pauses, p)
+	}
+
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].Pattern < pauses[j].Pattern })
+	return pauses
+}
+
+// Restore replaces the runtime Pauses, eg: from a state file
+func (s *Set) Restore(pauses []*Pause) error {
+	api := make(map[string]*Pause)
+	for _, p := range pauses {
+		if _, err := path.Match(p.Pattern, ""); err != nil {
+			return fmt.Errorf("Invalid task pattern '%s': %w", p.Pattern, err)
+		}
+
+		restored := *p
+		restored.Source = SourceAPI
+		api[p.Pattern] = &restored
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.api = api
+	return nil
+}
+
+// Wrap returns a TaskRunner which does not run any task paused "at" the given
+// time, instead giving a PausedError as a warning
+func (s *Set) Wrap(runner taskrunner.TaskRunner, at time.Time) taskrunner.TaskRunner {
+	active := s.Active(at)
+	if len(active) == 0 {
+		return runner
+	}
+
+	suppressor := suppression.NewSuppressionTaskRunner(runner)
+	for _, p := range active {
+		// patterns are validated when added
+		_ = suppressor.SuppressMatching(p.Pattern, &PausedError{Pause: p})
+	}
+
+	return suppressor
+}
+
+// LoadFile reads a control file of paused tasks, one glob per line, each
+// optionally followed by "# reason"
+func LoadFile(r io.Reader) ([]*Pause, error) {
+	pauses := []*Pause{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+
+		line := scanner.Text()
+		reason := ""
+		if i := strings.Index(line, "#"); i >= 0 {
+			reason = strings.TrimSpace(line[i+1:])
+			line = line[:i]
+		}
+
+		pattern := strings.TrimSpace(line)
+		if pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil || strings.ContainsAny(pattern, " \t") {
+			return nil, fmt.Errorf("Line %d: Invalid task pattern '%s'", lineNumber, pattern)
+		}
+
+		pauses = append(pauses, &Pause{Pattern: pattern, Reason: reason, Source: SourceFile})
+	}
+
+	return pauses, scanner.Err()
+}
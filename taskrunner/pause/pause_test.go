@@ -0,0 +1,107 @@
+package pause
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wpalmer/ecscron/taskrunner"
+	"github.com/wpalmer/ecscron/taskrunner/suppression"
+)
+
+func TestSet(t *testing.T) {
+	at := time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)
+	runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+		return &taskrunner.TaskStatus{Ran: true}, nil
+	})
+
+	t.Run("Wrap should suppress paused tasks with a deliberate warning", func(t *testing.T) {
+		set := NewSet()
+		if err := set.Pause("billing-*", nil, "database migration"); err != nil {
+			t.Fatalf("Unexpected error pausing: %s", err)
+		}
+
+		wrapped := set.Wrap(runner, at)
+		result, _ := wrapped.RunTask("billing-report")
+		if result.Ran || len(result.Warnings) != 1 || !suppression.IsDeliberate(result.Warnings[0]) {
+			t.Fatalf("Paused task was not suppressed as expected: %+v", result)
+		}
+
+		if !strings.Contains(result.Warnings[0].Error(), "database migration") {
+			t.Fatalf("Warning did not include the reason: %s", result.Warnings[0])
+		}
+
+		if result, _ := wrapped.RunTask("other"); !result.Ran {
+			t.Fatalf("Task which was not paused did not run")
+		}
+	})
+
+	t.Run("Pauses should expire", func(t *testing.T) {
+		set := NewSet()
+		until := at.Add(time.Hour)
+		_ = set.Pause("test", &until, "")
+
+		if len(set.Active(at)) != 1 {
+			t.Fatalf("Pause was not active before it expired")
+		}
+
+		if len(set.Active(until)) != 0 || len(set.Runtime()) != 0 {
+			t.Fatalf("Pause was not forgotten once expired")
+		}
+	})
+
+	t.Run("Resume should only remove runtime pauses", func(t *testing.T) {
+		set := NewSet()
+		set.SetFile([]*Pause{&Pause{Pattern: "file", Source: SourceFile}})
+		_ = set.Pause("api", nil, "")
+
+		if set.Resume("file") {
+			t.Fatalf("Resume removed a pause from the control file")
+		}
+
+		if !set.Resume("api") || len(set.Active(at)) != 1 {
+			t.Fatalf("Resume did not remove only the runtime pause")
+		}
+	})
+
+	t.Run("Restore should replace the runtime pauses", func(t *testing.T) {
+		set := NewSet()
+		_ = set.Pause("old", nil, "")
+		if err := set.Restore([]*Pause{&Pause{Pattern: "new"}}); err != nil {
+			t.Fatalf("Unexpected error restoring: %s", err)
+		}
+
+		runtime := set.Runtime()
+		if len(runtime) != 1 || runtime[0].Pattern != "new" || runtime[0].Source != SourceAPI {
+			t.Fatalf("Restore did not replace the runtime pauses: %+v", runtime)
+		}
+	})
+
+	t.Run("Invalid patterns should be rejected", func(t *testing.T) {
+		if err := NewSet().Pause("[", nil, ""); err == nil {
+			t.Fatalf("Invalid pattern was accepted")
+		}
+	})
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Run("Should read patterns and reasons", func(t *testing.T) {
+		pauses, err := LoadFile(strings.NewReader("# comment\n\nbilling-* # migration\nreport\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error loading: %s", err)
+		}
+
+		if len(pauses) != 2 || pauses[0].Pattern != "billing-*" || pauses[0].Reason != "migration" ||
+			pauses[1].Pattern != "report" || pauses[1].Source != SourceFile {
+			t.Fatalf("Pauses were not loaded as expected: %+v", pauses)
+		}
+	})
+
+	t.Run("Should reject invalid lines", func(t *testing.T) {
+		for _, line := range []string{"[", "two words"} {
+			if _, err := LoadFile(strings.NewReader(line)); err == nil {
+				t.Fatalf("Invalid line '%s' was accepted", line)
+			}
+		}
+	})
+}
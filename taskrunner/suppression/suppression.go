@@ -1,29 +1,96 @@
 package suppression
 
 import (
+	"path"
+	"sort"
+	"sync"
+
 	"github.com/wpalmer/ecscron/taskrunner"
 )
 
+// A Deliberate reason for suppression (eg: a maintenance window, or a paused
+// task) means the task was intentionally not run, rather than anything having
+// gone wrong
+type Deliberate interface {
+	error
+	Deliberate()
+}
+
+// IsDeliberate returns true if the given warning is a Deliberate reason for
+// suppression
+func IsDeliberate(warning error) bool {
+	_, ok := warning.(Deliberate)
+	return ok
+}
+
+// A SuppressionTaskRunner passes tasks through to another TaskRunner, unless
+// suppressed. It is safe for concurrent use, provided the wrapped TaskRunner
+// is.
 type SuppressionTaskRunner struct {
-	runner taskrunner.TaskRunner
-	tasks  map[string]error
+	mu       sync.RWMutex
+	runner   taskrunner.TaskRunner
+	tasks    map[string]error
+	patterns map[string]error
 }
 
 func NewSuppressionTaskRunner(runner taskrunner.TaskRunner) *SuppressionTaskRunner {
 	return &SuppressionTaskRunner{
-		runner: runner,
-		tasks:  make(map[string]error),
+		runner:   runner,
+		tasks:    make(map[string]error),
+		patterns: make(map[string]error),
 	}
 }
 
-func (r SuppressionTaskRunner) Suppress(task string, reason error) {
+func (r *SuppressionTaskRunner) Suppress(task string, reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.tasks[task] = reason
 }
 
-func (r SuppressionTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+// SuppressMatching suppresses every task whose name matches the given glob
+// pattern (as understood by path.Match). Tasks suppressed by name take
+// precedence over those suppressed by pattern.
+func (r *SuppressionTaskRunner) SuppressMatching(pattern string, reason error) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.patterns[pattern] = reason
+	return nil
+}
+
+func (r *SuppressionTaskRunner) reason(task string) (error, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if reason, ok := r.tasks[task]; ok {
+		return reason, true
+	}
+
+	// check in a consistent order, in case more than one pattern matches
+	patterns := make([]string, 0, len(r.patterns))
+	for pattern := range r.patterns {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, task); matched {
+			return r.patterns[pattern], true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *SuppressionTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
 	var reason error
 	var ok bool
-	if reason, ok = r.tasks[task]; !ok {
+	if reason, ok = r.reason(task); !ok {
 		return r.runner.RunTask(task)
 	}
 
@@ -2,6 +2,8 @@ package suppression
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/wpalmer/ecscron/taskrunner"
@@ -103,3 +105,92 @@ func TestTaskRunner(t *testing.T) {
 		}
 	})
 }
+
+func TestSuppressMatching(t *testing.T) {
+	t.Run("Should not pass-through on matching RunTask", func(t *testing.T) {
+		var passedTasks []string
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			passedTasks = append(passedTasks, task)
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+		suppressor := NewSuppressionTaskRunner(runner)
+		reason := errors.New("testReason")
+		if err := suppressor.SuppressMatching("test-*", reason); err != nil {
+			t.Fatalf("Valid pattern was rejected: %s", err)
+		}
+
+		result, _ := suppressor.RunTask("test-a")
+		if result.Ran {
+			t.Fatalf("Task matching a suppressed pattern claims to have run")
+		}
+
+		if len(result.Warnings) != 1 || result.Warnings[0] != reason {
+			t.Fatalf("Suppressed task Status does not have the reason as the warning")
+		}
+
+		_, _ = suppressor.RunTask("other")
+		if len(passedTasks) != 1 || passedTasks[0] != "other" {
+			t.Fatalf("Only the non-matching task should have been passed through: %v", passedTasks)
+		}
+	})
+
+	t.Run("Should prefer a reason given by name", func(t *testing.T) {
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+		suppressor := NewSuppressionTaskRunner(runner)
+		byName := errors.New("byName")
+		_ = suppressor.SuppressMatching("*", errors.New("byPattern"))
+		suppressor.Suppress("test", byName)
+
+		result, _ := suppressor.RunTask("test")
+		if len(result.Warnings) != 1 || result.Warnings[0] != byName {
+			t.Fatalf("Suppressed task Status does not have the named reason as the warning")
+		}
+	})
+
+	t.Run("Should be safe for concurrent use", func(t *testing.T) {
+		runner := taskrunner.TaskRunnerFunc(func(task string) (*taskrunner.TaskStatus, error) {
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+		suppressor := NewSuppressionTaskRunner(runner)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				task := fmt.Sprintf("test-%d", i)
+				suppressor.Suppress(task, errors.New("intentional"))
+				if result, _ := suppressor.RunTask(task); result.Ran {
+					t.Errorf("Task '%s' was not suppressed", task)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	t.Run("Should reject invalid patterns", func(t *testing.T) {
+		suppressor := NewSuppressionTaskRunner(nil)
+		if err := suppressor.SuppressMatching("[", nil); err == nil {
+			t.Fatalf("Invalid pattern was accepted")
+		}
+	})
+}
+
+type deliberateError struct{}
+
+func (e *deliberateError) Error() string { return "deliberate" }
+func (e *deliberateError) Deliberate()   {}
+
+func TestIsDeliberate(t *testing.T) {
+	t.Run("Should recognise only Deliberate reasons", func(t *testing.T) {
+		if !IsDeliberate(&deliberateError{}) {
+			t.Fatalf("Deliberate reason was not recognised")
+		}
+
+		if IsDeliberate(errors.New("intentional")) {
+			t.Fatalf("Ordinary error was recognised as Deliberate")
+		}
+	})
+}
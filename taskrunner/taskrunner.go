@@ -10,6 +10,11 @@ type TaskStatus struct {
 	// (optional) note that the task is known to be "already running", prior to this tick
 	Running bool
 
+	// (optional) note that the task was not run because the API throttled the
+	// request. This is transient, so the task should be tried again, without
+	// counting as a failed attempt.
+	Throttled bool
+
 	// Permanent or undefined / unknown error
 	Error error
 
@@ -26,3 +31,22 @@ type TaskRunnerFunc func(task string) (*TaskStatus, error)
 func (r TaskRunnerFunc) RunTask(task string) (*TaskStatus, error) {
 	return r(task)
 }
+
+// A DefinitionTaskRunner can run a task from a task definition with another
+// name (eg: with a prefix), while still identifying the task by its own name
+// (eg: to find copies already running).
+type DefinitionTaskRunner interface {
+	TaskRunner
+	RunTaskDefinition(task string, definition string) (*TaskStatus, error)
+}
+
+type DefinitionTaskRunnerFunc func(task string, definition string) (*TaskStatus, error)
+
+// RunTask runs the task from the definition of the same name
+func (r DefinitionTaskRunnerFunc) RunTask(task string) (*TaskStatus, error) {
+	return r(task, task)
+}
+
+func (r DefinitionTaskRunnerFunc) RunTaskDefinition(task string, definition string) (*TaskStatus, error) {
+	return r(task, definition)
+}
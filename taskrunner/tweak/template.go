@@ -0,0 +1,119 @@
+package tweak
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var mappingMatcher = regexp.MustCompile("^\\s*([^\\s#]+)\\s+([^\\s#:]+(?::[0-9]+)?)\\s*(?:#.*)?$")
+var ignoredMatcher = regexp.MustCompile("^\\s*(?:#.*)?$")
+
+// A Lookup gives the template parameters and options of each task, eg: a
+// Crontab
+type Lookup interface {
+	Parameters(task string) map[string]string
+	Options(task string) map[string]string
+}
+
+// TemplateData is given to a task template, for each task
+type TemplateData struct {
+	// the name of the task in the crontab
+	Task string
+
+	// the environment of ecscron
+	Env map[string]string
+
+	// the parameters of a templated entry, by name
+	Params map[string]string
+
+	// the options of the task's (first) entry, eg: "priority"
+	Options map[string]string
+}
+
+// NewTemplateTranslator returns a Translator which renders a text/template
+// for each task. The given tasks are rendered in advance, so that any error
+// (such as a missing key) is returned immediately, and any other task is left
+// as-is.
+func NewTemplateTranslator(text string, tasks []string, lookup Lookup, env map[string]string) (Translator, error) {
+	tmpl, err := template.New("task").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make(map[string]string)
+	for _, task := range tasks {
+		data := &TemplateData{
+			Task:    task,
+			Env:     env,
+			Params:  lookup.Parameters(task),
+			Options: lookup.Options(task),
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			return nil, fmt.Errorf("Failed to render the template for '%s': %s", task, err)
+		}
+
+		translation := strings.TrimSpace(rendered.String())
+		if translation == "" {
+			return nil, fmt.Errorf("The template for '%s' rendered an empty name", task)
+		}
+
+		translations[task] = translation
+	}
+
+	return func(task string) string {
+		if translation, ok := translations[task]; ok {
+			return translation
+		}
+
+		return task
+	}, nil
+}
+
+// LoadMapping reads a mapping from task names to task definitions (a family,
+// or "family:revision"), one per line, as "<task> <definition>"
+func LoadMapping(r io.Reader) (map[string]string, error) {
+	mapping := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ignoredMatcher.MatchString(line) {
+			continue
+		}
+
+		matches := mappingMatcher.FindStringSubmatch(line)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("Invalid mapping '%s': expected '<task> <family>[:<revision>]'", line)
+		}
+
+		if _, ok := mapping[matches[1]]; ok {
+			return nil, fmt.Errorf("Task '%s' is mapped more than once", matches[1])
+		}
+
+		mapping[matches[1]] = matches[2]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// Mapped returns a Translator which gives the mapped task definition of any
+// task in the mapping, and otherwise that of the fallback Translator
+func Mapped(mapping map[string]string, fallback Translator) Translator {
+	return func(task string) string {
+		if definition, ok := mapping[task]; ok {
+			return definition
+		}
+
+		return fallback(task)
+	}
+}
@@ -18,6 +18,33 @@ func NewTweakTaskRunner(runner taskrunner.TaskRunner, translator Translator) *Tw
 	}
 }
 
+// RunTask runs the task from its translated task definition. A
+// DefinitionTaskRunner is still given the original name of the task, so that
+// several tasks may share one definition.
 func (r TweakTaskRunner) RunTask(task string) (*taskrunner.TaskStatus, error) {
+	if runner, ok := r.runner.(taskrunner.DefinitionTaskRunner); ok {
+		return runner.RunTaskDefinition(task, r.translator(task))
+	}
+
 	return r.runner.RunTask(r.translator(task))
 }
+
+// Identify returns the name by which the copies of each of the tasks are
+// known (eg: their startedBy in ECS): the task definition of the task, unless
+// it is shared by several of the tasks, in which case the task's own name, so
+// that they are told apart.
+func Identify(tasks []string, translator Translator) Translator {
+	counts := make(map[string]int)
+	for _, task := range tasks {
+		counts[translator(task)] += 1
+	}
+
+	return func(task string) string {
+		definition := translator(task)
+		if counts[definition] > 1 {
+			return task
+		}
+
+		return definition
+	}
+}
@@ -1,6 +1,7 @@
 package tweak
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/wpalmer/ecscron/taskrunner"
@@ -29,3 +30,126 @@ func TestTweakTaskRunner(t *testing.T) {
 		}
 	})
 }
+
+func TestTweakDefinitionTaskRunner(t *testing.T) {
+	t.Run("A DefinitionTaskRunner should be given the task and its definition", func(t *testing.T) {
+		var passedTask, passedDefinition string
+		runner := taskrunner.DefinitionTaskRunnerFunc(func(task string, definition string) (*taskrunner.TaskStatus, error) {
+			passedTask, passedDefinition = task, definition
+			return &taskrunner.TaskStatus{Ran: true}, nil
+		})
+
+		_, _ = NewTweakTaskRunner(runner, func(task string) string { return "Tweaked" }).RunTask("test")
+		if passedTask != "test" || passedDefinition != "Tweaked" {
+			t.Fatalf("Unexpected task and definition: %s, %s", passedTask, passedDefinition)
+		}
+	})
+}
+
+type lookup map[string]map[string]string
+
+func (l lookup) Parameters(task string) map[string]string {
+	return l[task]
+}
+
+func (l lookup) Options(task string) map[string]string {
+	return map[string]string{"priority": "5"}
+}
+
+func TestTemplateTranslator(t *testing.T) {
+	tasks := []string{"report-a", "cleanup"}
+	params := lookup{"report-a": {"tenant": "a"}}
+	env := map[string]string{"ENV": "prod", "REGION": "eu-west-1"}
+
+	t.Run("The template should be given the task, environment, parameters and options", func(t *testing.T) {
+		translate, err := NewTemplateTranslator(
+			`{{.Env.ENV}}-{{.Task}}-{{.Env.REGION}}{{with index .Params "tenant"}}-t{{.}}{{end}}-p{{.Options.priority}}`,
+			tasks, params, env)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if translated := translate("report-a"); translated != "prod-report-a-eu-west-1-ta-p5" {
+			t.Fatalf("Unexpected translation: %s", translated)
+		}
+
+		if translated := translate("cleanup"); translated != "prod-cleanup-eu-west-1-p5" {
+			t.Fatalf("Unexpected translation: %s", translated)
+		}
+
+		if translated := translate("unknown"); translated != "unknown" {
+			t.Fatalf("An unknown task was translated: %s", translated)
+		}
+	})
+
+	t.Run("Errors should be returned when the translator is created", func(t *testing.T) {
+		for _, text := range []string{
+			"{{.Task",
+			"{{.Env.MISSING}}-{{.Task}}",
+			"{{if false}}{{.Task}}{{end}}",
+		} {
+			if _, err := NewTemplateTranslator(text, tasks, params, env); err == nil {
+				t.Fatalf("An invalid template did not fail: %s", text)
+			}
+		}
+	})
+}
+
+func TestMapping(t *testing.T) {
+	t.Run("Mapped tasks should be given their task definition", func(t *testing.T) {
+		mapping, err := LoadMapping(strings.NewReader(
+			"# logical name -> task definition\n" +
+				"report  reporting-v2\n" +
+				"cleanup cleanup:42 # pinned\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		translate := Mapped(mapping, func(task string) string { return "prefix-" + task })
+		for task, expected := range map[string]string{
+			"report":  "reporting-v2",
+			"cleanup": "cleanup:42",
+			"other":   "prefix-other",
+		} {
+			if translated := translate(task); translated != expected {
+				t.Fatalf("'%s' was translated to '%s' rather than '%s'", task, translated, expected)
+			}
+		}
+	})
+
+	t.Run("Invalid mappings should fail", func(t *testing.T) {
+		for _, text := range []string{
+			"report\n",
+			"report a b\n",
+			"report family:latest\n",
+			"report a\nreport b\n",
+		} {
+			if _, err := LoadMapping(strings.NewReader(text)); err == nil {
+				t.Fatalf("Loading an invalid mapping succeeded: %q", text)
+			}
+		}
+	})
+}
+
+func TestIdentify(t *testing.T) {
+	t.Run("Tasks should be identified by their definition, unless it is shared", func(t *testing.T) {
+		identify := Identify([]string{"report-a", "report-b", "extract"}, func(task string) string {
+			if strings.HasPrefix(task, "report-") {
+				return "prod-report"
+			}
+
+			return "prod-" + task
+		})
+
+		expected := map[string]string{
+			"report-a": "report-a",
+			"report-b": "report-b",
+			"extract":  "prod-extract",
+		}
+		for task, identity := range expected {
+			if actual := identify(task); actual != identity {
+				t.Fatalf("'%s' was identified as '%s', rather than '%s'", task, actual, identity)
+			}
+		}
+	})
+}